@@ -0,0 +1,315 @@
+// Command benchmark measures face verification accuracy and speed of go-face
+// on an LFW-style pairs list, e.g. pairs.txt of
+// <http://vis-www.cs.umass.edu/lfw/>, and writes a JSON report to compare
+// configurations and releases.
+//
+//	benchmark -models models -images lfw -pairs pairs.txt -jitter 1 -out report.json
+//
+// Every line of the pairs list is either "name n1 n2" for two images of
+// the same person or "name1 n1 name2 n2" for different persons, image n of
+// name being at <images>/<name>/<name>_<n as %04d>.jpg. A first line with
+// only numbers (folds and pairs per fold) is skipped.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	face "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+)
+
+// Config is the configuration being benchmarked.
+type Config struct {
+	Models    string
+	Pairs     string
+	CNN       bool
+	Size      int
+	Padding   float32
+	Jitter    int
+	Tolerance float32
+}
+
+// Stage is the timing of a processing stage over all images: decoding the
+// image file, encoding it to JPEG for dlib, detecting and embedding the
+// faces.
+type Stage struct {
+	Count   int
+	TotalMs float64
+	MeanMs  float64
+}
+
+// Report is written as JSON.
+type Report struct {
+	Config Config
+	Pairs  int
+	// Evaluated pairs are those whose both images gave a descriptor.
+	Evaluated int
+	// Failed holds the images without a face or that couldn't be read,
+	// with the reason.
+	Failed map[string]string
+	// Accuracy and the confusion counts are at Config.Tolerance.
+	Accuracy       float64
+	TruePositives  int
+	FalsePositives int
+	TrueNegatives  int
+	FalseNegatives int
+	// BestAccuracy is reached at BestTolerance.
+	BestAccuracy  float64
+	BestTolerance float32
+	Stages        map[string]*Stage
+}
+
+type pair struct {
+	img1, img2 string
+	same       bool
+}
+
+func main() {
+	var cfg Config
+	imagesDir := flag.String("images", "lfw", "directory with one subdirectory of images per person")
+	out := flag.String("out", "", "JSON report file, stdout if empty")
+	flag.StringVar(&cfg.Models, "models", "models", "directory with the dlib models")
+	flag.StringVar(&cfg.Pairs, "pairs", "pairs.txt", "pairs list")
+	flag.BoolVar(&cfg.CNN, "cnn", false, "detect faces with the CNN instead of HOG")
+	flag.IntVar(&cfg.Size, "size", 150, "size of the face chips")
+	flag.Var(float32Value{&cfg.Padding}, "padding", "padding around the face chips")
+	flag.IntVar(&cfg.Jitter, "jitter", 0, "number of jittered copies averaged per descriptor")
+	cfg.Padding, cfg.Tolerance = 0.25, 0.36
+	flag.Var(float32Value{&cfg.Tolerance}, "tolerance", "max squared euclidean distance of the same person")
+	flag.Parse()
+
+	pairs, err := readPairs(cfg.Pairs, *imagesDir)
+	if err != nil {
+		log.Fatalf("Can't read pairs: %v", err)
+	}
+	rec, err := face.NewRecognizerWithConfig(cfg.Models, cfg.Size, cfg.Padding, cfg.Jitter)
+	if err != nil {
+		log.Fatalf("Can't init face recognizer: %v", err)
+	}
+	defer rec.Close()
+
+	report := &Report{
+		Config: cfg,
+		Pairs:  len(pairs),
+		Failed: make(map[string]string),
+		Stages: map[string]*Stage{"decode": {}, "encode": {}, "detect": {}, "embed": {}},
+	}
+	descriptors := make(map[string]*face.Descriptor)
+	describe := func(path string) *face.Descriptor {
+		if d, ok := descriptors[path]; ok {
+			return d
+		}
+		d, err := describeImage(rec, cfg.CNN, path, report.Stages)
+		if err != nil {
+			report.Failed[path] = err.Error()
+		}
+		descriptors[path] = d
+		return d
+	}
+
+	var genuine, impostor []float32
+	for _, p := range pairs {
+		d1, d2 := describe(p.img1), describe(p.img2)
+		if d1 == nil || d2 == nil {
+			continue
+		}
+		dist := float32(face.SquaredEuclideanDistance(*d1, *d2))
+		if p.same {
+			genuine = append(genuine, dist)
+		} else {
+			impostor = append(impostor, dist)
+		}
+	}
+	report.Evaluated = len(genuine) + len(impostor)
+	report.score(genuine, impostor)
+	for _, s := range report.Stages {
+		if s.Count > 0 {
+			s.MeanMs = s.TotalMs / float64(s.Count)
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("Can't encode report: %v", err)
+	}
+	if *out == "" {
+		fmt.Println(string(data))
+		return
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("Can't write report: %v", err)
+	}
+}
+
+// describeImage returns the descriptor of the largest face of the image,
+// timing every stage.
+func describeImage(rec *face.Recognizer, cnn bool, path string, stages map[string]*Stage) (*face.Descriptor, error) {
+	timed := func(stage string, fn func() error) error {
+		start := time.Now()
+		err := fn()
+		stages[stage].Count++
+		stages[stage].TotalMs += float64(time.Since(start)) / float64(time.Millisecond)
+		return err
+	}
+
+	var img image.Image
+	err := timed("decode", func() (err error) {
+		img, err = face.DefaultLimits.Open(path)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The image is encoded once, the detector and the embedder take the
+	// JPEG as is unless it exceeds face.DefaultLimits.MaxDimension.
+	var data bytes.Buffer
+	err = timed("encode", func() error {
+		return imag.Encode(&data, img, imag.JPEG)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var dets []face.Detection
+	err = timed("detect", func() (err error) {
+		if cnn {
+			dets, err = rec.DetectFacesCNN(data.Bytes(), false)
+		} else {
+			dets, err = rec.DetectFaces(data.Bytes(), false)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dets) == 0 {
+		return nil, fmt.Errorf("no face detected")
+	}
+	// LFW images are centered on a single person, others are background.
+	largest := dets[0].Rectangle
+	for _, d := range dets[1:] {
+		if d.Rectangle.Dx()*d.Rectangle.Dy() > largest.Dx()*largest.Dy() {
+			largest = d.Rectangle
+		}
+	}
+	var faces []face.Face
+	err = timed("embed", func() (err error) {
+		faces, err = rec.RecognizeRects(data.Bytes(), []image.Rectangle{largest})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(faces) != 1 {
+		return nil, fmt.Errorf("no descriptor computed")
+	}
+	return &faces[0].Descriptor, nil
+}
+
+// score fills the accuracy at the configured tolerance and the best one.
+func (r *Report) score(genuine, impostor []float32) {
+	if r.Evaluated == 0 {
+		return
+	}
+	for _, d := range genuine {
+		if d <= r.Config.Tolerance {
+			r.TruePositives++
+		} else {
+			r.FalseNegatives++
+		}
+	}
+	for _, d := range impostor {
+		if d <= r.Config.Tolerance {
+			r.FalsePositives++
+		} else {
+			r.TrueNegatives++
+		}
+	}
+	r.Accuracy = float64(r.TruePositives+r.TrueNegatives) / float64(r.Evaluated)
+
+	// Accuracy only changes at observed distances.
+	sort.Slice(genuine, func(i, j int) bool { return genuine[i] < genuine[j] })
+	sort.Slice(impostor, func(i, j int) bool { return impostor[i] < impostor[j] })
+	for _, dists := range [][]float32{genuine, impostor} {
+		for _, t := range dists {
+			tp := sort.Search(len(genuine), func(i int) bool { return genuine[i] > t })
+			fp := sort.Search(len(impostor), func(i int) bool { return impostor[i] > t })
+			acc := float64(tp+len(impostor)-fp) / float64(r.Evaluated)
+			if acc > r.BestAccuracy {
+				r.BestAccuracy, r.BestTolerance = acc, t
+			}
+		}
+	}
+}
+
+// readPairs parses the pairs list into image paths.
+func readPairs(path, imagesDir string) ([]pair, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	image := func(name, n string) (string, error) {
+		num, err := strconv.Atoi(n)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(imagesDir, name, fmt.Sprintf("%s_%04d.jpg", name, num)), nil
+	}
+
+	var pairs []pair
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		var p pair
+		var err1, err2 error
+		switch len(fields) {
+		case 0:
+			continue
+		case 3:
+			p.same = true
+			p.img1, err1 = image(fields[0], fields[1])
+			p.img2, err2 = image(fields[0], fields[2])
+		case 4:
+			p.img1, err1 = image(fields[0], fields[1])
+			p.img2, err2 = image(fields[2], fields[3])
+		default:
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected 3 or 4 fields", line)
+		}
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("line %d: invalid image number", line)
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, scanner.Err()
+}
+
+// float32Value is a flag.Value for float32 options.
+type float32Value struct{ p *float32 }
+
+func (v float32Value) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(*v.p), 'g', -1, 32)
+}
+
+func (v float32Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 32)
+	*v.p = float32(f)
+	return err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPairs(t *testing.T) {
+	tests := []struct {
+		name  string
+		list  string
+		pairs []pair
+		ok    bool
+	}{
+		{"lfw", "10\t300\nAbel_Pacheco\t1\t4\n\nAbdel_Madi_Shabneh\t1\tDean_Barker\t12\n", []pair{
+			{filepath.Join("lfw", "Abel_Pacheco", "Abel_Pacheco_0001.jpg"), filepath.Join("lfw", "Abel_Pacheco", "Abel_Pacheco_0004.jpg"), true},
+			{filepath.Join("lfw", "Abdel_Madi_Shabneh", "Abdel_Madi_Shabneh_0001.jpg"), filepath.Join("lfw", "Dean_Barker", "Dean_Barker_0012.jpg"), false},
+		}, true},
+		{"no header", "Abel_Pacheco 1 4\n", []pair{
+			{filepath.Join("lfw", "Abel_Pacheco", "Abel_Pacheco_0001.jpg"), filepath.Join("lfw", "Abel_Pacheco", "Abel_Pacheco_0004.jpg"), true},
+		}, true},
+		{"fields", "10 300\nAbel_Pacheco 1\n", nil, false},
+		{"number", "Abel_Pacheco 1 four\n", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pairs.txt")
+			if err := os.WriteFile(path, []byte(tt.list), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pairs, err := readPairs(path, "lfw")
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && !reflect.DeepEqual(pairs, tt.pairs) {
+				t.Errorf("got %v, want %v", pairs, tt.pairs)
+			}
+		})
+	}
+}
+
+func TestReportScore(t *testing.T) {
+	r := Report{Config: Config{Tolerance: 0.4}, Evaluated: 6}
+	r.score([]float32{0.3, 0.5, 0.2}, []float32{0.45, 0.7, 0.35})
+
+	if r.TruePositives != 2 || r.FalseNegatives != 1 || r.FalsePositives != 1 || r.TrueNegatives != 2 {
+		t.Errorf("got TP %d, FN %d, FP %d, TN %d, want 2, 1, 1, 2", r.TruePositives, r.FalseNegatives, r.FalsePositives, r.TrueNegatives)
+	}
+	if r.Accuracy != 4.0/6 {
+		t.Errorf("got accuracy %v, want %v", r.Accuracy, 4.0/6)
+	}
+	// Accepting up to 0.3 rejects the genuine pair at 0.5 and no impostor.
+	if r.BestAccuracy != 5.0/6 || r.BestTolerance != 0.3 {
+		t.Errorf("got best accuracy %v at %v, want %v at 0.3", r.BestAccuracy, r.BestTolerance, 5.0/6)
+	}
+
+	empty := Report{Config: Config{Tolerance: 0.4}}
+	empty.score(nil, nil)
+	if empty.Accuracy != 0 || empty.BestAccuracy != 0 {
+		t.Errorf("scored %v and %v without evaluated pairs", empty.Accuracy, empty.BestAccuracy)
+	}
+}
@@ -0,0 +1,17 @@
+package face
+
+// #include <stdint.h>
+// #include "facerec.h"
+import "C"
+
+// makeError constructs Go error for passed error info.
+func makeError(s string, code int) error {
+	switch code {
+	case C.IMAGE_LOAD_ERROR:
+		return ImageLoadError(s)
+	case C.SERIALIZATION_ERROR:
+		return SerializationError(s)
+	default:
+		return UnknownError(s)
+	}
+}
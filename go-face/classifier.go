@@ -0,0 +1,262 @@
+package face
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Metric selects how the distance between two descriptors is measured.
+// Tolerances passed to ClassifyThreshold are expressed in its units.
+type Metric int
+
+const (
+	// SquaredEuclidean is the metric used by the dlib classifier.
+	SquaredEuclidean Metric = iota
+	// Cosine is 1 minus the cosine similarity, in [0, 2].
+	Cosine
+)
+
+// Distance returns the distance between d1 and d2.
+func (m Metric) Distance(d1, d2 Descriptor) float32 {
+	return m.distance(&d1, &d2)
+}
+
+// distance avoids copying descriptors in hot loops.
+func (m Metric) distance(d1, d2 *Descriptor) float32 {
+	switch m {
+	case Cosine:
+		var dot, n1, n2 float64
+		for i := range d1 {
+			dot += float64(d1[i]) * float64(d2[i])
+			n1 += float64(d1[i]) * float64(d1[i])
+			n2 += float64(d2[i]) * float64(d2[i])
+		}
+		if n1 == 0 || n2 == 0 {
+			return 1
+		}
+		return float32(1 - dot/math.Sqrt(n1*n2))
+	default:
+		var sum float32
+		for i := range d1 {
+			diff := d1[i] - d2[i]
+			sum += diff * diff
+		}
+		return sum
+	}
+}
+
+// Strategy selects how the category is chosen from the known samples.
+type Strategy int
+
+const (
+	// Vote picks the category with most samples among the K nearest, ties
+	// are broken by the nearest sample like the dlib classifier.
+	Vote Strategy = iota
+	// WeightedVote weighs the votes of the K nearest samples by the inverse
+	// of their distance.
+	WeightedVote
+	// NearestCentroid compares with the mean descriptor of each category.
+	NearestCentroid
+)
+
+// ClassifierOptions configures a Classifier. Zero value behaves like the
+// dlib classifier: top-10 vote on squared euclidean distance.
+type ClassifierOptions struct {
+	K        int
+	Metric   Metric
+	Strategy Strategy
+	// IndexThreshold is the number of samples above which the nearest
+	// samples are searched in an approximate Index instead of a linear
+	// scan, 0 meaning never. Ignored by NearestCentroid.
+	IndexThreshold int
+	// Index configures the Index, its Metric is replaced by Metric.
+	Index IndexOptions
+}
+
+// A Classifier classifies descriptors against known samples in pure Go, so
+// it also works in builds without cgo. It has the same classification
+// methods as Recognizer. Thread-safe.
+type Classifier struct {
+	opt       ClassifierOptions
+	mu        sync.RWMutex
+	samples   []Descriptor
+	cats      []int32
+	centroids []Descriptor
+	centCats  []int32
+	index     *Index
+}
+
+// A Neighbor is a known sample and its distance to a query.
+type Neighbor struct {
+	Index    int
+	Cat      int32
+	Distance float32
+}
+
+// NewClassifier returns a classifier without samples.
+func NewClassifier(opt ClassifierOptions) *Classifier {
+	if opt.K <= 0 {
+		opt.K = 10
+	}
+	return &Classifier{opt: opt}
+}
+
+// SetSamples sets known descriptors so you can classify the new ones.
+func (c *Classifier) SetSamples(samples []Descriptor, cats []int32) {
+	if len(samples) != len(cats) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples = append([]Descriptor(nil), samples...)
+	c.cats = append([]int32(nil), cats...)
+	c.centroids, c.centCats = centroids(c.samples, c.cats)
+	if c.opt.IndexThreshold <= 0 || len(samples) <= c.opt.IndexThreshold {
+		c.index = nil
+		return
+	}
+	if c.index == nil || c.index.opt.Metric != c.opt.Metric || c.index.opt.Encoding != c.opt.Index.Encoding {
+		opt := c.opt.Index
+		opt.Metric = c.opt.Metric
+		c.index = NewIndex(opt)
+	}
+	// Only the samples changed since the last call are inserted.
+	c.index.Sync(c.samples, c.cats)
+}
+
+// Metric returns the metric the classifier measures distances with.
+func (c *Classifier) Metric() Metric {
+	return c.opt.Metric
+}
+
+// Index returns the index used for the current samples, nil if they are
+// scanned linearly.
+func (c *Classifier) Index() *Index {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index
+}
+
+// SetIndex sets a previously saved index, so that the next SetSamples only
+// updates it instead of building a new one.
+func (c *Classifier) SetIndex(ix *Index) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index = ix
+}
+
+// Classify returns class ID for the given descriptor. Negative index is
+// returned if no match.
+func (c *Classifier) Classify(testSample Descriptor) int {
+	return c.ClassifyThreshold(testSample, -1)
+}
+
+// ClassifyThreshold Same as Classify but only samples within tolerance
+// are considered, negative tolerance meaning no limit.
+func (c *Classifier) ClassifyThreshold(testSample Descriptor, tolerance float32) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.opt.Strategy == NearestCentroid {
+		best := c.nearest(c.centroids, c.centCats, testSample, tolerance, 1)
+		if len(best) == 0 {
+			return -1
+		}
+		return int(best[0].Cat)
+	}
+	return vote(c.nearestSamples(testSample, tolerance, c.opt.K), c.opt.Strategy == WeightedVote)
+}
+
+// Nearest returns the k known samples nearest to testSample, sorted by
+// distance.
+func (c *Classifier) Nearest(testSample Descriptor, k int) []Neighbor {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nearestSamples(testSample, -1, k)
+}
+
+// nearestSamples searches the index if there is one, the samples otherwise.
+func (c *Classifier) nearestSamples(testSample Descriptor, tolerance float32, k int) []Neighbor {
+	if c.index == nil {
+		return c.nearest(c.samples, c.cats, testSample, tolerance, k)
+	}
+	neighbors := c.index.Search(testSample, k)
+	for i, n := range neighbors {
+		if tolerance >= 0 && n.Distance > tolerance {
+			return neighbors[:i]
+		}
+	}
+	return neighbors
+}
+
+func (c *Classifier) nearest(samples []Descriptor, cats []int32, testSample Descriptor, tolerance float32, k int) []Neighbor {
+	neighbors := make([]Neighbor, 0, len(samples))
+	for i := range samples {
+		dist := c.opt.Metric.distance(&samples[i], &testSample)
+		if tolerance < 0 || dist <= tolerance {
+			neighbors = append(neighbors, Neighbor{Index: i, Cat: cats[i], Distance: dist})
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].Distance < neighbors[j].Distance
+	})
+	if len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+// vote returns the category with most (weighted) votes among neighbors
+// sorted by distance, ties are broken by the nearest neighbor.
+func vote(neighbors []Neighbor, weighted bool) int {
+	if len(neighbors) == 0 {
+		return -1
+	}
+	scores := make(map[int32]float64)
+	first := make(map[int32]int)
+	for i, n := range neighbors {
+		w := 1.0
+		if weighted {
+			w = 1 / (float64(n.Distance) + 1e-6)
+		}
+		if _, ok := first[n.Cat]; !ok {
+			first[n.Cat] = i
+		}
+		scores[n.Cat] += w
+	}
+	best := neighbors[0].Cat
+	for cat, score := range scores {
+		if score > scores[best] || (score == scores[best] && first[cat] < first[best]) {
+			best = cat
+		}
+	}
+	return int(best)
+}
+
+// centroids returns the mean descriptor of every category.
+func centroids(samples []Descriptor, cats []int32) ([]Descriptor, []int32) {
+	index := make(map[int32]int)
+	var sums []Descriptor
+	var counts []int
+	var centCats []int32
+	for i, s := range samples {
+		j, ok := index[cats[i]]
+		if !ok {
+			j = len(sums)
+			index[cats[i]] = j
+			sums = append(sums, Descriptor{})
+			counts = append(counts, 0)
+			centCats = append(centCats, cats[i])
+		}
+		for k := range s {
+			sums[j][k] += s[k]
+		}
+		counts[j]++
+	}
+	for j := range sums {
+		for k := range sums[j] {
+			sums[j][k] /= float32(counts[j])
+		}
+	}
+	return sums, centCats
+}
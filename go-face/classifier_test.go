@@ -0,0 +1,43 @@
+package face
+
+import "testing"
+
+func TestClassifierStrategies(t *testing.T) {
+	axis := func(i int, v float32) Descriptor {
+		var d Descriptor
+		d[i] = v
+		return d
+	}
+	// Category 0 has the nearest sample, category 1 most of the 3 nearest
+	// and the nearest centroid.
+	samples := []Descriptor{axis(0, 0.3), axis(1, 0.35), axis(2, 0.36), axis(3, 2)}
+	cats := []int32{0, 1, 1, 2}
+
+	tests := []struct {
+		name      string
+		opt       ClassifierOptions
+		query     Descriptor
+		tolerance float32
+		want      int
+	}{
+		{"nearest", ClassifierOptions{K: 1}, Descriptor{}, -1, 0},
+		{"vote", ClassifierOptions{K: 3}, Descriptor{}, -1, 1},
+		{"vote tie", ClassifierOptions{K: 2}, Descriptor{}, -1, 0},
+		{"weighted vote", ClassifierOptions{K: 3, Strategy: WeightedVote}, Descriptor{}, -1, 1},
+		{"weighted near", ClassifierOptions{K: 3, Strategy: WeightedVote}, axis(0, 0.29), -1, 0},
+		{"nearest centroid", ClassifierOptions{Strategy: NearestCentroid}, Descriptor{}, -1, 1},
+		{"tolerance", ClassifierOptions{K: 3}, Descriptor{}, 0.1, 0},
+		{"out of tolerance", ClassifierOptions{K: 3}, Descriptor{}, 0.05, -1},
+		{"centroid out of tolerance", ClassifierOptions{Strategy: NearestCentroid}, Descriptor{}, 0.05, -1},
+		{"cosine", ClassifierOptions{K: 1, Metric: Cosine}, axis(3, 0.1), -1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClassifier(tt.opt)
+			c.SetSamples(samples, cats)
+			if got := c.ClassifyThreshold(tt.query, tt.tolerance); got != tt.want {
+				t.Errorf("ClassifyThreshold = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
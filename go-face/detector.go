@@ -0,0 +1,13 @@
+package face
+
+import "image"
+
+// A Detector finds faces on a decoded image. Recognizer implements it with
+// the dlib HOG detector, PicoDetector is a pure Go implementation usable in
+// builds without cgo.
+type Detector interface {
+	// DetectImage returns all faces found on img sorted from left to right.
+	// Shapes are filled only if landmarks is true and the detector supports
+	// them.
+	DetectImage(img image.Image, landmarks bool) ([]Detection, error)
+}
@@ -1,8 +1,13 @@
 package face
 
-// #include <stdint.h>
-// #include "facerec.h"
-import "C"
+import (
+	"errors"
+	"strings"
+)
+
+// ErrCgoDisabled is returned by the dlib based recognizers when the package
+// is built without cgo. Use a pure Go Detector such as PicoDetector instead.
+var ErrCgoDisabled = errors.New("go-face: dlib is not available, built without cgo")
 
 // An ImageLoadError is returned when provided image file is corrupted.
 type ImageLoadError string
@@ -11,6 +16,14 @@ func (e ImageLoadError) Error() string {
 	return string(e)
 }
 
+// An ImageTooLargeError is returned when provided image exceeds the
+// configured Limits.
+type ImageTooLargeError string
+
+func (e ImageTooLargeError) Error() string {
+	return string(e)
+}
+
 // An SerializationError is returned when provided model is corrupted.
 type SerializationError string
 
@@ -18,21 +31,39 @@ func (e SerializationError) Error() string {
 	return string(e)
 }
 
+// A ModelError is returned when model files are missing or corrupted.
+type ModelError struct {
+	Dir     string
+	Missing []string
+	Corrupt []string
+}
+
+func (e *ModelError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Corrupt) > 0 {
+		parts = append(parts, "corrupt "+strings.Join(e.Corrupt, ", "))
+	}
+	return "invalid models in " + e.Dir + ": " + strings.Join(parts, "; ")
+}
+
+// lists reports whether the file is missing or corrupt.
+func (e *ModelError) lists(name string) bool {
+	for _, names := range [][]string{e.Missing, e.Corrupt} {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // An UnknownError represents some nonclassified error.
 type UnknownError string
 
 func (e UnknownError) Error() string {
 	return string(e)
 }
-
-// makeError constructs Go error for passed error info.
-func makeError(s string, code int) error {
-	switch code {
-	case C.IMAGE_LOAD_ERROR:
-		return ImageLoadError(s)
-	case C.SERIALIZATION_ERROR:
-		return SerializationError(s)
-	default:
-		return UnknownError(s)
-	}
-}
@@ -7,15 +7,13 @@ package face
 // #include "facerec.h"
 import "C"
 import (
-	"bufio"
-	"bytes"
+	"fmt"
 	"image"
 	"io"
-	"math"
+	"io/fs"
 	"os"
+	"sync"
 	"unsafe"
-
-	"github.com/oarkflow/imaging/imag"
 )
 
 const (
@@ -33,43 +31,143 @@ const (
 // A Recognizer creates face descriptors for provided images and
 // classifies them into categories.
 type Recognizer struct {
-	ptr *C.facerec
+	ptr    *C.facerec
+	limits Limits
+	// model is the index of the embedding model in models.
+	model  int
+	models *modelSet
 }
 
-// Face holds coordinates and descriptor of the human face.
-type Face struct {
-	Rectangle  image.Rectangle
-	Descriptor Descriptor
-	Shapes     []image.Point
+// modelSet holds the IDs of the embedding models registered in the C
+// layer, shared by the views returned by WithModel.
+type modelSet struct {
+	mu  sync.RWMutex
+	ids []string
 }
 
-// Descriptor holds 128-dimensional feature vector.
-type Descriptor [128]float32
-
-func SquaredEuclideanDistance(d1 Descriptor, d2 Descriptor) (sum float64) {
-	for i := range d1 {
-		sum = sum + math.Pow(float64(d2[i]-d1[i]), 2)
-	}
-
-	return sum
+func newModelSet() *modelSet {
+	return &modelSet{ids: []string{DefaultModel}}
 }
 
-// New creates new face with the provided parameters.
-func New(r image.Rectangle, d Descriptor) Face {
-	return Face{r, d, []image.Point{}}
+func (s *modelSet) index(id string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, m := range s.ids {
+		if m == id {
+			return i
+		}
+	}
+	return -1
 }
 
-func NewWithShape(r image.Rectangle, s []image.Point, d Descriptor) Face {
-	return Face{r, d, s}
+func (s *modelSet) id(index int) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ids[index]
 }
 
 // NewRecognizer returns a new recognizer interface. modelDir points to
 // directory with shape_predictor_5_face_landmarks.dat and
-// dlib_face_recognition_resnet_model_v1.dat files.
+// dlib_face_recognition_resnet_model_v1.dat files, plus
+// mmod_human_face_detector.dat if CNN methods are used. The files are
+// verified against DefaultManifest and the shape predictor and ResNet
+// models are deserialized up front, *ModelError lists all the missing or
+// corrupted files. The CNN model is deserialized on first use.
 func NewRecognizer(modelDir string) (rec *Recognizer, err error) {
+	return NewRecognizerWithManifest(modelDir, DefaultManifest)
+}
+
+// NewRecognizerWithManifest Same as NewRecognizer but verifies the model
+// files against the provided manifest.
+func NewRecognizerWithManifest(modelDir string, manifest Manifest) (rec *Recognizer, err error) {
+	return newRecognizer(modelDir, manifest, ShapePredictorModel)
+}
+
+// NewRecognizerWithShapePredictor Same as NewRecognizer but loads the shape
+// predictor file shapePredictor of modelDir instead of
+// ShapePredictorModel, e.g. ShapePredictor68Model whose eye outlines are
+// needed for blink detection. Face.Shapes then holds 68 points per face.
+func NewRecognizerWithShapePredictor(modelDir string, shapePredictor string) (rec *Recognizer, err error) {
+	return newRecognizer(modelDir, DefaultManifest, shapePredictor)
+}
+
+func newRecognizer(modelDir string, manifest Manifest, shapePredictor string) (rec *Recognizer, err error) {
+	merr, err := asModelError(checkModelDir(modelDir, manifest, shapePredictor), modelDir)
+	if err != nil {
+		return
+	}
 	cModelDir := C.CString(modelDir)
 	defer C.free(unsafe.Pointer(cModelDir))
-	ptr := C.facerec_init(cModelDir)
+	cShapePredictor := C.CString(shapePredictor)
+	defer C.free(unsafe.Pointer(cShapePredictor))
+	ptr := C.facerec_init(cModelDir, cShapePredictor)
+
+	if ptr.err_str != nil {
+		defer C.facerec_free(ptr)
+		defer C.free(unsafe.Pointer(ptr.err_str))
+		err = makeError(C.GoString(ptr.err_str), int(ptr.err_code))
+		return
+	}
+	if err = loadModels(ptr, merr, shapePredictor); err != nil {
+		C.facerec_free(ptr)
+		return
+	}
+
+	rec = &Recognizer{ptr: ptr, limits: DefaultLimits, models: newModelSet()}
+	return
+}
+
+// asModelError returns the *ModelError reported by a check, or a new one if
+// it passed, to which the models failing to deserialize are added. Other
+// errors are returned as is.
+func asModelError(err error, dir string) (*ModelError, error) {
+	if err == nil {
+		return &ModelError{Dir: dir}, nil
+	}
+	if merr, ok := err.(*ModelError); ok {
+		return merr, nil
+	}
+	return nil, err
+}
+
+// loadModels deserializes the shape predictor and the ResNet model, so that
+// corrupted files not caught by the manifest are reported by the
+// constructors instead of by the first recognition. Failures are added to
+// merr, which is returned if it lists any file.
+func loadModels(ptr *C.facerec, merr *ModelError, shapePredictor string) error {
+	failed := int(C.facerec_load(ptr))
+	for _, m := range []struct {
+		flag int
+		name string
+	}{
+		{C.SHAPE_PREDICTOR_MODEL, shapePredictor},
+		{C.RESNET_MODEL, ResNetModel},
+	} {
+		if failed&m.flag != 0 && !merr.lists(m.name) {
+			merr.Corrupt = append(merr.Corrupt, m.name)
+		}
+	}
+	if len(merr.Missing) > 0 || len(merr.Corrupt) > 0 {
+		return merr
+	}
+	return nil
+}
+
+// NewRecognizerFromData Same as NewRecognizer but deserializes the models
+// from memory instead of a directory, which allows shipping them inside the
+// binary with embed.FS.
+func NewRecognizerFromData(models ModelData) (rec *Recognizer, err error) {
+	merr, err := asModelError(models.check(), "memory")
+	if err != nil {
+		return
+	}
+	shapePredictor := models.shapePredictorName()
+	cShapePredictor := C.CString(shapePredictor)
+	defer C.free(unsafe.Pointer(cShapePredictor))
+	cSp, cSpLen := cBytes(models.ShapePredictor)
+	cNet, cNetLen := cBytes(models.ResNet)
+	cCNN, cCNNLen := cBytes(models.CNN)
+	ptr := C.facerec_init_mem(cShapePredictor, cSp, cSpLen, cNet, cNetLen, cCNN, cCNNLen)
 
 	if ptr.err_str != nil {
 		defer C.facerec_free(ptr)
@@ -77,11 +175,108 @@ func NewRecognizer(modelDir string) (rec *Recognizer, err error) {
 		err = makeError(C.GoString(ptr.err_str), int(ptr.err_code))
 		return
 	}
+	if err = loadModels(ptr, merr, shapePredictor); err != nil {
+		C.facerec_free(ptr)
+		return
+	}
 
-	rec = &Recognizer{ptr}
+	rec = &Recognizer{ptr: ptr, limits: DefaultLimits, models: newModelSet()}
 	return
 }
 
+// NewRecognizerFromFS Same as NewRecognizer but reads the model files from
+// dir of fsys, e.g. an embed.FS.
+func NewRecognizerFromFS(fsys fs.FS, dir string) (rec *Recognizer, err error) {
+	return NewRecognizerFromFSWithShapePredictor(fsys, dir, ShapePredictorModel)
+}
+
+// NewRecognizerFromFSWithShapePredictor Same as NewRecognizerFromFS but
+// loads the shape predictor file shapePredictor of dir, see
+// NewRecognizerWithShapePredictor.
+func NewRecognizerFromFSWithShapePredictor(fsys fs.FS, dir string, shapePredictor string) (rec *Recognizer, err error) {
+	models, err := ReadModelDataWithShapePredictor(fsys, dir, shapePredictor)
+	if err != nil {
+		return
+	}
+	return NewRecognizerFromData(models)
+}
+
+// AddModel registers an additional embedding model under id, e.g. a
+// retrained dlib_face_recognition_resnet_model_v1.dat. It must have the same
+// network architecture as the default model. Use WithModel to compute
+// descriptors with it.
+func (rec *Recognizer) AddModel(id string, modelPath string) error {
+	cModelPath := C.CString(modelPath)
+	defer C.free(unsafe.Pointer(cModelPath))
+	return rec.addModel(id, func() *C.modelret {
+		return C.facerec_add_model(rec.ptr, cModelPath)
+	})
+}
+
+// AddModelData Same as AddModel but deserializes the model from memory.
+func (rec *Recognizer) AddModelData(id string, model []byte) error {
+	cName := C.CString(id)
+	defer C.free(unsafe.Pointer(cName))
+	cModel, cModelLen := cBytes(model)
+	return rec.addModel(id, func() *C.modelret {
+		return C.facerec_add_model_mem(rec.ptr, cName, cModel, cModelLen)
+	})
+}
+
+func (rec *Recognizer) addModel(id string, add func() *C.modelret) error {
+	rec.models.mu.Lock()
+	defer rec.models.mu.Unlock()
+	for _, m := range rec.models.ids {
+		if m == id {
+			return fmt.Errorf("go-face: model %q already registered", id)
+		}
+	}
+	ret := add()
+	defer C.free(unsafe.Pointer(ret))
+	if ret.err_str != nil {
+		defer C.free(unsafe.Pointer(ret.err_str))
+		return makeError(C.GoString(ret.err_str), int(ret.err_code))
+	}
+	// The C layer numbers models in registration order too.
+	rec.models.ids = append(rec.models.ids, id)
+	return nil
+}
+
+// Models returns the IDs of the registered embedding models, DefaultModel
+// first.
+func (rec *Recognizer) Models() []string {
+	rec.models.mu.RLock()
+	defer rec.models.mu.RUnlock()
+	return append([]string(nil), rec.models.ids...)
+}
+
+// WithModel returns a view of the recognizer computing descriptors with
+// the given embedding model. Views share the loaded models, detectors and
+// samples: only Close the original recognizer, which invalidates them.
+func (rec *Recognizer) WithModel(id string) (*Recognizer, error) {
+	index := rec.models.index(id)
+	if index < 0 {
+		return nil, fmt.Errorf("go-face: unknown model %q", id)
+	}
+	view := *rec
+	view.model = index
+	return &view, nil
+}
+
+// Model returns the ID of the embedding model of the recognizer.
+func (rec *Recognizer) Model() string {
+	return rec.models.id(rec.model)
+}
+
+// cBytes returns pointer and length of data to pass to the C layer, which
+// copies it.
+func cBytes(data []byte) (*C.uint8_t, C.int) {
+	if len(data) == 0 {
+		return nil, 0
+	}
+	return (*C.uint8_t)(&data[0]), C.int(len(data))
+}
+
 func NewRecognizerWithConfig(modelDir string, size int, padding float32, jittering int) (rec *Recognizer, err error) {
 	rec, err = NewRecognizer(modelDir)
 	if err != nil {
@@ -106,8 +301,43 @@ func (rec *Recognizer) recognize(type_ int, imgData []byte, maxFaces int) (faces
 	cLen := C.int(len(imgData))
 	cMaxFaces := C.int(maxFaces)
 	cType := C.int(type_)
+	cModel := C.int(rec.model)
+
+	ret := C.facerec_recognize(rec.ptr, cImgData, cLen, cMaxFaces, cType, cModel)
+	return copyFaces(ret, rec.Model())
+}
+
+func (rec *Recognizer) recognizeRects(imgData []byte, rects []image.Rectangle) (faces []Face, err error) {
+	if len(imgData) == 0 {
+		err = ImageLoadError("Empty image")
+		return
+	}
+	if len(rects) == 0 {
+		return
+	}
+	if len(rects) > maxFaceLimit {
+		rects = rects[:maxFaceLimit]
+	}
+	rData := make([]C.long, len(rects)*rectLen)
+	for i, r := range rects {
+		rData[i*rectLen] = C.long(r.Min.X)
+		rData[i*rectLen+1] = C.long(r.Min.Y)
+		rData[i*rectLen+2] = C.long(r.Max.X)
+		rData[i*rectLen+3] = C.long(r.Max.Y)
+	}
+	cImgData := (*C.uint8_t)(&imgData[0])
+	cLen := C.int(len(imgData))
+	cRects := (*C.long)(&rData[0])
+	cNumRects := C.int(len(rects))
+	cModel := C.int(rec.model)
 
-	ret := C.facerec_recognize(rec.ptr, cImgData, cLen, cMaxFaces, cType)
+	ret := C.facerec_recognize_rects(rec.ptr, cImgData, cLen, cRects, cNumRects, cModel)
+	return copyFaces(ret, rec.Model())
+}
+
+// copyFaces converts the C recognition result to Go structures and frees it.
+// Faces are tagged with the ID of the model which computed them.
+func copyFaces(ret *C.faceret, model string) (faces []Face, err error) {
 	defer C.free(unsafe.Pointer(ret))
 
 	if ret.err_str != nil {
@@ -140,7 +370,7 @@ func (rec *Recognizer) recognize(type_ int, imgData []byte, maxFaces int) (faces
 	sData := (*[maxElements]C.long)(sDataPtr)[:sDataLen:sDataLen]
 
 	for i := 0; i < numFaces; i++ {
-		face := Face{}
+		face := Face{Model: model}
 		x0 := int(rData[i*rectLen])
 		y0 := int(rData[i*rectLen+1])
 		x1 := int(rData[i*rectLen+2])
@@ -157,6 +387,70 @@ func (rec *Recognizer) recognize(type_ int, imgData []byte, maxFaces int) (faces
 	return
 }
 
+func (rec *Recognizer) detect(type_ int, imgData []byte, landmarks bool) (dets []Detection, err error) {
+	if len(imgData) == 0 {
+		err = ImageLoadError("Empty image")
+		return
+	}
+	cImgData := (*C.uint8_t)(&imgData[0])
+	cLen := C.int(len(imgData))
+	cType := C.int(type_)
+	cLandmarks := C.int(0)
+	if landmarks {
+		cLandmarks = 1
+	}
+
+	ret := C.facerec_detect(rec.ptr, cImgData, cLen, cType, cLandmarks)
+	defer C.free(unsafe.Pointer(ret))
+
+	if ret.err_str != nil {
+		defer C.free(unsafe.Pointer(ret.err_str))
+		err = makeError(C.GoString(ret.err_str), int(ret.err_code))
+		return
+	}
+
+	numFaces := int(ret.num_faces)
+	if numFaces == 0 {
+		return
+	}
+	numShapes := int(ret.num_shapes)
+
+	defer C.free(unsafe.Pointer(ret.shapes))
+	defer C.free(unsafe.Pointer(ret.rectangles))
+	defer C.free(unsafe.Pointer(ret.scores))
+
+	rDataLen := numFaces * rectLen
+	rDataPtr := unsafe.Pointer(ret.rectangles)
+	rData := (*[maxElements]C.long)(rDataPtr)[:rDataLen:rDataLen]
+
+	cDataPtr := unsafe.Pointer(ret.scores)
+	cData := (*[maxElements]float64)(cDataPtr)[:numFaces:numFaces]
+
+	sDataLen := numFaces * numShapes * shapeLen
+	var sData []C.long
+	if sDataLen > 0 {
+		sDataPtr := unsafe.Pointer(ret.shapes)
+		sData = (*[maxElements]C.long)(sDataPtr)[:sDataLen:sDataLen]
+	}
+
+	for i := 0; i < numFaces; i++ {
+		det := Detection{}
+		x0 := int(rData[i*rectLen])
+		y0 := int(rData[i*rectLen+1])
+		x1 := int(rData[i*rectLen+2])
+		y1 := int(rData[i*rectLen+3])
+		det.Rectangle = image.Rect(x0, y0, x1, y1)
+		det.Confidence = cData[i]
+		for j := 0; j < numShapes; j++ {
+			shapeX := int(sData[(i*numShapes+j)*shapeLen])
+			shapeY := int(sData[(i*numShapes+j)*shapeLen+1])
+			det.Shapes = append(det.Shapes, image.Point{shapeX, shapeY})
+		}
+		dets = append(dets, det)
+	}
+	return
+}
+
 func (rec *Recognizer) recognizeFileOld(type_ int, imgPath string, maxFaces int) (face []Face, err error) {
 	fd, err := os.Open(imgPath)
 	if err != nil {
@@ -170,23 +464,62 @@ func (rec *Recognizer) recognizeFileOld(type_ int, imgPath string, maxFaces int)
 	return rec.recognize(type_, imgData, maxFaces)
 }
 
-func (rec *Recognizer) recognizeFile(type_ int, imgPath string, maxFaces int) (face []Face, err error) {
-	var b bytes.Buffer
-	w := bufio.NewWriter(&b)
-
-	fd, err := os.Open(imgPath)
+func (rec *Recognizer) recognizeFile(type_ int, imgPath string, maxFaces int) (faces []Face, err error) {
+	imgData, factor, err := rec.prepareFile(imgPath)
 	if err != nil {
 		return
 	}
-	defer fd.Close()
-	img, err := imag.Decode(fd, imag.AutoOrientation(true))
+	faces, err = rec.recognize(type_, imgData, maxFaces)
+	return scaleFaces(faces, factor), err
+}
+
+func (rec *Recognizer) recognizeData(type_ int, imgData []byte, maxFaces int) (faces []Face, err error) {
+	imgData, factor, err := rec.prepare(imgData)
 	if err != nil {
-		return nil, err
+		return
+	}
+	faces, err = rec.recognize(type_, imgData, maxFaces)
+	return scaleFaces(faces, factor), err
+}
+
+func (rec *Recognizer) recognizeRectsData(imgData []byte, rects []image.Rectangle, factor float64) (faces []Face, err error) {
+	if factor != 1 {
+		scaled := make([]image.Rectangle, len(rects))
+		for i, r := range rects {
+			scaled[i] = scaleRect(r, 1/factor)
+		}
+		rects = scaled
 	}
-	if err := imag.Encode(w, img, imag.JPEG); err != nil {
-		return nil, err
+	faces, err = rec.recognizeRects(imgData, rects)
+	return scaleFaces(faces, factor), err
+}
+
+// prepare checks the image against the limits and prepares it for the C
+// layer with Limits.prepare.
+func (rec *Recognizer) prepare(imgData []byte) ([]byte, float64, error) {
+	return rec.limits.prepare(imgData)
+}
+
+// prepareFile Same as prepare but decodes the image file with auto
+// orientation and re-encodes it to JPEG, the only format understood by the
+// C layer.
+func (rec *Recognizer) prepareFile(imgPath string) ([]byte, float64, error) {
+	img, err := rec.limits.Open(imgPath)
+	if err != nil {
+		return nil, 1, err
 	}
-	return rec.recognize(type_, b.Bytes(), maxFaces)
+	return rec.limits.encode(img)
+}
+
+func (rec *Recognizer) prepareImage(img image.Image) ([]byte, float64, error) {
+	return rec.limits.encode(img)
+}
+
+// SetLimits sets the size limits checked before decoding images and the
+// maximum dimension passed to the detector. DefaultLimits are used
+// otherwise. Not thread-safe.
+func (rec *Recognizer) SetLimits(limits Limits) {
+	rec.limits = limits
 }
 
 // Recognize returns all faces found on the provided image, sorted from
@@ -194,17 +527,17 @@ func (rec *Recognizer) recognizeFile(type_ int, imgPath string, maxFaces int) (f
 // returned if there was some error while decoding/processing image.
 // Only JPEG format is currently supported. Thread-safe.
 func (rec *Recognizer) Recognize(imgData []byte) (faces []Face, err error) {
-	return rec.recognize(0, imgData, 10)
+	return rec.recognizeData(0, imgData, 10)
 }
 
 func (rec *Recognizer) RecognizeCNN(imgData []byte) (faces []Face, err error) {
-	return rec.recognize(1, imgData, 10)
+	return rec.recognizeData(1, imgData, 10)
 }
 
 // RecognizeSingle returns face if it's the only face on the image or
 // nil otherwise. Only JPEG format is currently supported. Thread-safe.
 func (rec *Recognizer) RecognizeSingle(imgData []byte) (face *Face, err error) {
-	faces, err := rec.recognize(0, imgData, 1)
+	faces, err := rec.recognizeData(0, imgData, 1)
 	if err != nil || len(faces) != 1 {
 		return
 	}
@@ -213,7 +546,7 @@ func (rec *Recognizer) RecognizeSingle(imgData []byte) (face *Face, err error) {
 }
 
 func (rec *Recognizer) RecognizeSingleCNN(imgData []byte) (face *Face, err error) {
-	faces, err := rec.recognize(1, imgData, 0)
+	faces, err := rec.recognizeData(1, imgData, 0)
 	if err != nil || len(faces) != 1 {
 		return
 	}
@@ -249,6 +582,123 @@ func (rec *Recognizer) RecognizeSingleFileCNN(imgPath string) (face *Face, err e
 	return
 }
 
+// RecognizeRects computes descriptors for the faces inside the provided
+// rectangles, skipping face detection entirely. Useful when face locations
+// are already known from annotations or another detector. Faces are returned
+// in the same order as rects. Only JPEG format is currently supported.
+// Thread-safe.
+func (rec *Recognizer) RecognizeRects(imgData []byte, rects []image.Rectangle) (faces []Face, err error) {
+	imgData, factor, err := rec.prepare(imgData)
+	if err != nil {
+		return
+	}
+	return rec.recognizeRectsData(imgData, rects, factor)
+}
+
+// RecognizeFileRects Same as RecognizeRects but accepts image path instead.
+func (rec *Recognizer) RecognizeFileRects(imgPath string, rects []image.Rectangle) (faces []Face, err error) {
+	imgData, factor, err := rec.prepareFile(imgPath)
+	if err != nil {
+		return
+	}
+	return rec.recognizeRectsData(imgData, rects, factor)
+}
+
+// DetectFaces returns rectangles and confidence scores of all faces found
+// on the provided image using the HOG detector, sorted from left to right.
+// Descriptors are not computed, which makes it much faster than Recognize.
+// If landmarks is true face shapes are computed as well. Only JPEG format
+// is currently supported. Thread-safe.
+func (rec *Recognizer) DetectFaces(imgData []byte, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepare(imgData)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(0, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
+// DetectFacesCNN Same as DetectFaces but uses the MMOD CNN detector.
+func (rec *Recognizer) DetectFacesCNN(imgData []byte, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepare(imgData)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(1, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
+// DetectFacesFile Same as DetectFaces but accepts image path instead.
+func (rec *Recognizer) DetectFacesFile(imgPath string, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepareFile(imgPath)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(0, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
+// DetectFacesFileCNN Same as DetectFacesCNN but accepts image path instead.
+func (rec *Recognizer) DetectFacesFileCNN(imgPath string, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepareFile(imgPath)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(1, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
+// RecognizeImage Same as Recognize but accepts decoded image instead.
+func (rec *Recognizer) RecognizeImage(img image.Image) (faces []Face, err error) {
+	imgData, factor, err := rec.prepareImage(img)
+	if err != nil {
+		return
+	}
+	faces, err = rec.recognize(0, imgData, 10)
+	return scaleFaces(faces, factor), err
+}
+
+// RecognizeImageCNN Same as RecognizeCNN but accepts decoded image instead.
+func (rec *Recognizer) RecognizeImageCNN(img image.Image) (faces []Face, err error) {
+	imgData, factor, err := rec.prepareImage(img)
+	if err != nil {
+		return
+	}
+	faces, err = rec.recognize(1, imgData, 10)
+	return scaleFaces(faces, factor), err
+}
+
+// RecognizeImageRects Same as RecognizeRects but accepts decoded image
+// instead.
+func (rec *Recognizer) RecognizeImageRects(img image.Image, rects []image.Rectangle) (faces []Face, err error) {
+	imgData, factor, err := rec.prepareImage(img)
+	if err != nil {
+		return
+	}
+	return rec.recognizeRectsData(imgData, rects, factor)
+}
+
+// DetectImage Same as DetectFaces but accepts decoded image instead.
+// Implements Detector.
+func (rec *Recognizer) DetectImage(img image.Image, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepareImage(img)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(0, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
+// DetectImageCNN Same as DetectFacesCNN but accepts decoded image instead.
+func (rec *Recognizer) DetectImageCNN(img image.Image, landmarks bool) (dets []Detection, err error) {
+	imgData, factor, err := rec.prepareImage(img)
+	if err != nil {
+		return
+	}
+	dets, err = rec.detect(1, imgData, landmarks)
+	return scaleDetections(dets, factor), err
+}
+
 // SetSamples sets known descriptors so you can classify the new ones.
 // Thread-safe.
 func (rec *Recognizer) SetSamples(samples []Descriptor, cats []int32) {
@@ -272,8 +722,9 @@ func (rec *Recognizer) Classify(testSample Descriptor) int {
 	return int(C.facerec_classify(rec.ptr, cTestSample, -1))
 }
 
-// Same as Classify but allows to specify max distance between faces to
-// consider it a match. Start with 0.6 if not sure.
+// Same as Classify but allows to specify max squared euclidean distance
+// between faces to consider it a match. Start with 0.36, the square of the
+// 0.6 threshold recommended by dlib, if not sure.
 func (rec *Recognizer) ClassifyThreshold(testSample Descriptor, tolerance float32) int {
 	cTestSample := (*C.float)(unsafe.Pointer(&testSample))
 	cTolerance := C.float(tolerance)
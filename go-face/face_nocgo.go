@@ -0,0 +1,179 @@
+//go:build !cgo
+
+package face
+
+import (
+	"image"
+	"io/fs"
+)
+
+// Without cgo dlib is not available: the constructors and methods below
+// keep the API of the package and return ErrCgoDisabled, so that code
+// depending on it still builds and can fall back to PicoDetector.
+
+// A Recognizer creates face descriptors for provided images and
+// classifies them into categories.
+type Recognizer struct{}
+
+// ObjRecognizer detects objects with a custom trained dlib detector.
+type ObjRecognizer struct{}
+
+func NewRecognizer(modelDir string) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerWithManifest(modelDir string, manifest Manifest) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerWithShapePredictor(modelDir string, shapePredictor string) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerFromData(models ModelData) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerFromFS(fsys fs.FS, dir string) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerFromFSWithShapePredictor(fsys fs.FS, dir string, shapePredictor string) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewRecognizerWithConfig(modelDir string, size int, padding float32, jittering int) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) SetLimits(limits Limits) {}
+
+func (rec *Recognizer) AddModel(id string, modelPath string) error {
+	return ErrCgoDisabled
+}
+
+func (rec *Recognizer) AddModelData(id string, model []byte) error {
+	return ErrCgoDisabled
+}
+
+func (rec *Recognizer) Models() []string {
+	return nil
+}
+
+func (rec *Recognizer) WithModel(id string) (*Recognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) Model() string {
+	return DefaultModel
+}
+
+func (rec *Recognizer) Recognize(imgData []byte) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeCNN(imgData []byte) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeSingle(imgData []byte) (*Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeSingleCNN(imgData []byte) (*Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeFile(imgPath string) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeFileCNN(imgPath string) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeSingleFile(imgPath string) (*Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeSingleFileCNN(imgPath string) (*Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeRects(imgData []byte, rects []image.Rectangle) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeFileRects(imgPath string, rects []image.Rectangle) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeImage(img image.Image) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeImageCNN(img image.Image) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) RecognizeImageRects(img image.Image, rects []image.Rectangle) ([]Face, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectFaces(imgData []byte, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectFacesCNN(imgData []byte, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectFacesFile(imgPath string, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectFacesFileCNN(imgPath string, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectImage(img image.Image, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) DetectImageCNN(img image.Image, landmarks bool) ([]Detection, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *Recognizer) SetSamples(samples []Descriptor, cats []int32) {}
+
+func (rec *Recognizer) Classify(testSample Descriptor) int {
+	return -1
+}
+
+func (rec *Recognizer) ClassifyThreshold(testSample Descriptor, tolerance float32) int {
+	return -1
+}
+
+func (rec *Recognizer) Close() {}
+
+func NewObjRecognizer(modelDir string) (*ObjRecognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewObjRecognizerWithManifest(modelPath string, manifest Manifest) (*ObjRecognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewObjRecognizerFromData(model []byte) (*ObjRecognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func NewObjRecognizerFromFS(fsys fs.FS, name string) (*ObjRecognizer, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *ObjRecognizer) Recognize(imgData []byte) ([]image.Rectangle, error) {
+	return nil, ErrCgoDisabled
+}
+
+func (rec *ObjRecognizer) Close() {}
@@ -0,0 +1,108 @@
+//go:build cgo
+
+package face
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+// Models and images of the go-face-testdata layout, the models being
+// downloaded separately.
+var (
+	testModelsDir = filepath.Join("..", "examples", "models")
+	testImagesDir = filepath.Join("..", "examples", "images")
+)
+
+func newTestRecognizer(t *testing.T) *Recognizer {
+	t.Helper()
+	rec, err := NewRecognizer(testModelsDir)
+	if err != nil {
+		t.Skipf("models unavailable: %v", err)
+	}
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func TestRecognizeRects(t *testing.T) {
+	rec := newTestRecognizer(t)
+	path := filepath.Join(testImagesDir, "elenco1.jpg")
+	faces, err := rec.RecognizeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) < 2 {
+		t.Fatalf("found %d faces, want several", len(faces))
+	}
+	// Reversed rectangles give the faces in their order.
+	var rects []image.Rectangle
+	for i := len(faces) - 1; i >= 0; i-- {
+		rects = append(rects, faces[i].Rectangle)
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+	}{
+		{"original size", Limits{}},
+		{"downscaled", Limits{MaxDimension: 400}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec.SetLimits(tt.limits)
+			defer rec.SetLimits(DefaultLimits)
+			got, err := rec.RecognizeFileRects(path, rects)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(rects) {
+				t.Fatalf("got %d faces, want %d", len(got), len(rects))
+			}
+			for i, f := range got {
+				want := faces[len(faces)-1-i]
+				if d := SquaredEuclideanDistance(f.Descriptor, want.Descriptor); d > 0.1 {
+					t.Errorf("face %d is %v away from the detected one", i, d)
+				}
+			}
+		})
+	}
+
+	if got, err := rec.RecognizeFileRects(path, nil); err != nil || len(got) != 0 {
+		t.Errorf("got %d faces (%v) without rectangles, want none", len(got), err)
+	}
+}
+
+func TestDetectFaces(t *testing.T) {
+	rec := newTestRecognizer(t)
+	path := filepath.Join(testImagesDir, "elenco1.jpg")
+	faces, err := rec.RecognizeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		landmarks bool
+		shapes    int
+	}{
+		{"rectangles", false, 0},
+		{"landmarks", true, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dets, err := rec.DetectFacesFile(path, tt.landmarks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dets) != len(faces) {
+				t.Fatalf("detected %d faces, want the %d recognized", len(dets), len(faces))
+			}
+			for i, d := range dets {
+				if d.Rectangle != faces[i].Rectangle || len(d.Shapes) != tt.shapes {
+					t.Errorf("detection %d is %v with %d landmarks, want %v with %d", i, d.Rectangle, len(d.Shapes), faces[i].Rectangle, tt.shapes)
+				}
+			}
+		})
+	}
+}
@@ -1,4 +1,6 @@
+#include <memory>
 #include <shared_mutex>
+#include <sstream>
 #include <dlib/dnn.h>
 #include <dlib/image_loader/image_loader.h>
 #include <dlib/image_processing/frontal_face_detector.h>
@@ -57,19 +59,47 @@ static std::vector<matrix<rgb_pixel>> jitter_image(
     int count
 );
 
+static void fill_faceret(
+	faceret* ret,
+	const std::vector<rectangle>& rects,
+	const std::vector<descriptor>& descrs,
+	const std::vector<full_object_detection>& shapes
+);
+
+// Where to deserialize a model from: a file path or an in-memory copy of the
+// serialized model. Name is used in error messages in both cases.
+struct model_source {
+	std::string name;
+	std::string data;
+	bool in_memory;
+};
+
+static model_source file_model(const std::string& path) {
+	return {path, std::string(), false};
+}
+
+static model_source mem_model(const char* name, const uint8_t* data, int len) {
+	return {name, len > 0 ? std::string((const char*)data, len) : std::string(), true};
+}
+
+// Embedding model registered in addition to the default ResNet. It must
+// have the same architecture, e.g. a retrained version of it.
+struct extra_net {
+	anet_type net;
+	std::mutex mutex;
+};
+
 class FaceRec {
 public:
-	FaceRec(const char* model_dir) {
+	FaceRec(model_source&& sp, model_source&& net, model_source&& cnn_net) {
 		detector_ = get_frontal_face_detector();
 
-		std::string dir = model_dir;
-		std::string shape_predictor_path = dir + "/shape_predictor_5_face_landmarks.dat";
-		std::string resnet_path = dir + "/dlib_face_recognition_resnet_model_v1.dat";
-		std::string cnn_resnet_path = dir + "/mmod_human_face_detector.dat";
-
-		deserialize(shape_predictor_path) >> sp_;
-		deserialize(resnet_path) >> net_;
-		deserialize(cnn_resnet_path) >> cnn_net_;
+		// Models are deserialized on first use, or by Load for the
+		// required ones, so that unused features don't cost memory and
+		// startup time.
+		sp_src_ = std::move(sp);
+		net_src_ = std::move(net);
+		cnn_net_src_ = std::move(cnn_net);
 
 		jittering = 0;
 		size = 150;
@@ -77,42 +107,79 @@ public:
 	}
 
 	std::tuple<std::vector<rectangle>, std::vector<descriptor>, std::vector<full_object_detection>>
-	Recognize(const matrix<rgb_pixel>& img,int max_faces,int type) {
+	Recognize(const matrix<rgb_pixel>& img,int max_faces,int type,int model) {
 		std::vector<rectangle> rects;
 		std::vector<descriptor> descrs;
 		std::vector<full_object_detection> shapes;
 
-		if(type == 0) {
-			std::lock_guard<std::mutex> lock(detector_mutex_);
-			rects = detector_(img);
-		} else{
-			std::lock_guard<std::mutex> lock(cnn_net_mutex_);
-			auto dets = cnn_net_(img);
-            for (auto&& d : dets) {
-                rects.push_back(d.rect);
-            }
+		for (auto&& d : Locate(img, type)) {
+			rects.push_back(d.rect);
 		}
 
 		// Short circuit.
 		if (rects.size() == 0 || (max_faces > 0 && rects.size() > (size_t)max_faces))
 			return {std::move(rects), std::move(descrs), std::move(shapes)};
 
-		std::sort(rects.begin(), rects.end());
+		std::tie(descrs, shapes) = Describe(img, rects, model);
+
+		return {std::move(rects), std::move(descrs), std::move(shapes)};
+	}
+
+	std::tuple<std::vector<rect_detection>, std::vector<full_object_detection>>
+	Detect(const matrix<rgb_pixel>& img, int type, bool landmarks) {
+		std::vector<rect_detection> dets = Locate(img, type);
+		std::vector<full_object_detection> shapes;
+
+		if (landmarks) {
+			for (const auto& d : dets) {
+				shapes.push_back(ShapePredictor()(img, d.rect));
+			}
+		}
+
+		return {std::move(dets), std::move(shapes)};
+	}
+
+	std::tuple<std::vector<descriptor>, std::vector<full_object_detection>>
+	Describe(const matrix<rgb_pixel>& img, const std::vector<rectangle>& rects, int model) {
+		std::vector<descriptor> descrs;
+		std::vector<full_object_detection> shapes;
 
 		for (const auto& rect : rects) {
-			auto shape = sp_(img, rect);
+			auto shape = ShapePredictor()(img, rect);
 			shapes.push_back(shape);
 			matrix<rgb_pixel> face_chip;
 			extract_image_chip(img, get_face_chip_details(shape, size, padding), face_chip);
-			std::lock_guard<std::mutex> lock(net_mutex_);
-			if (jittering > 0) {
-				descrs.push_back(mean(mat(net_(jitter_image(std::move(face_chip), jittering)))));
-			} else {
-				descrs.push_back(net_(face_chip));
-			}
+			descrs.push_back(Embed(face_chip, model));
 		}
 
-		return {std::move(rects), std::move(descrs), std::move(shapes)};
+		return {std::move(descrs), std::move(shapes)};
+	}
+
+	// Deserializes the models needed for recognition, returns the
+	// model_flag of those which failed.
+	int Load() {
+		int failed = 0;
+		try {
+			ShapePredictor();
+		} catch (std::exception&) {
+			failed |= SHAPE_PREDICTOR_MODEL;
+		}
+		try {
+			Net();
+		} catch (std::exception&) {
+			failed |= RESNET_MODEL;
+		}
+		return failed;
+	}
+
+	// Registers an embedding model, returns its index for Recognize and
+	// Describe. The default ResNet is model 0.
+	int AddModel(model_source&& src) {
+		auto extra = std::make_unique<extra_net>();
+		load_model(src, extra->net);
+		std::unique_lock<std::shared_mutex> lock(models_mutex_);
+		extra_nets_.push_back(std::move(extra));
+		return extra_nets_.size();
 	}
 
   void SetSamples(std::vector<descriptor>&& samples, std::vector<int>&& cats) {
@@ -139,14 +206,114 @@ public:
   }
 
 private:
+	// Computes the descriptor of the face chip with the given model.
+	descriptor Embed(const matrix<rgb_pixel>& face_chip, int model) {
+		anet_type* net;
+		std::mutex* net_mutex;
+		if (model == 0) {
+			net = &Net();
+			net_mutex = &net_mutex_;
+		} else {
+			std::shared_lock<std::shared_mutex> lock(models_mutex_);
+			if (model < 0 || (size_t)model > extra_nets_.size())
+				throw std::invalid_argument("unknown embedding model");
+			net = &extra_nets_[model-1]->net;
+			net_mutex = &extra_nets_[model-1]->mutex;
+		}
+		std::lock_guard<std::mutex> lock(*net_mutex);
+		if (jittering > 0) {
+			return mean(mat((*net)(jitter_image(face_chip, jittering))));
+		}
+		return (*net)(face_chip);
+	}
+
+	// Runs HOG (type 0) or MMOD CNN (type 1) detector, results are sorted
+	// from left to right.
+	std::vector<rect_detection> Locate(const matrix<rgb_pixel>& img, int type) {
+		std::vector<rect_detection> dets;
+
+		if(type == 0) {
+			std::lock_guard<std::mutex> lock(detector_mutex_);
+			detector_(img, dets);
+		} else{
+			cnn_anet_type& cnn_net = CNNNet();
+			std::lock_guard<std::mutex> lock(cnn_net_mutex_);
+			for (auto&& d : cnn_net(img)) {
+				dets.push_back(rect_detection{d.detection_confidence, 0, d.rect});
+			}
+		}
+
+		std::sort(
+			dets.begin(), dets.end(),
+			[](const auto& a, const auto& b) { return a.rect < b.rect; }
+		);
+		return dets;
+	}
+
+	shape_predictor& ShapePredictor() {
+		std::lock_guard<std::mutex> lock(load_mutex_);
+		if (!sp_loaded_) {
+			load_model(sp_src_, sp_);
+			sp_loaded_ = true;
+		}
+		return sp_;
+	}
+
+	anet_type& Net() {
+		std::lock_guard<std::mutex> lock(load_mutex_);
+		if (!net_loaded_) {
+			load_model(net_src_, net_);
+			net_loaded_ = true;
+		}
+		return net_;
+	}
+
+	cnn_anet_type& CNNNet() {
+		std::lock_guard<std::mutex> lock(load_mutex_);
+		if (!cnn_net_loaded_) {
+			load_model(cnn_net_src_, cnn_net_);
+			cnn_net_loaded_ = true;
+		}
+		return cnn_net_;
+	}
+
+	// Deserializes the model, prefixing errors with its name so that a
+	// missing or corrupted model is easy to spot. In-memory copy is released
+	// once deserialized.
+	template <typename T>
+	static void load_model(model_source& src, T& model) {
+		try {
+			if (!src.in_memory) {
+				deserialize(src.name) >> model;
+			} else if (src.data.empty()) {
+				throw serialization_error("model data not provided");
+			} else {
+				std::istringstream in(src.data);
+				deserialize(model, in);
+				std::string().swap(src.data);
+			}
+		} catch (serialization_error& e) {
+			throw serialization_error(src.name + ": " + e.what());
+		}
+	}
+
+	std::mutex load_mutex_;
 	std::mutex detector_mutex_;
 	std::mutex net_mutex_;
 	std::mutex cnn_net_mutex_;
 	std::shared_mutex samples_mutex_;
+	std::shared_mutex models_mutex_;
 	frontal_face_detector detector_;
+	model_source sp_src_;
+	model_source net_src_;
+	model_source cnn_net_src_;
+	bool sp_loaded_ = false;
+	bool net_loaded_ = false;
+	bool cnn_net_loaded_ = false;
 	shape_predictor sp_;
 	anet_type net_;
 	cnn_anet_type cnn_net_;
+	std::vector<std::unique_ptr<extra_net>> extra_nets_;
 	std::vector<descriptor> samples_;
 	std::vector<int> cats_;
 	int jittering;
@@ -156,10 +323,39 @@ private:
 
 // Plain C interface for Go.
 
-facerec* facerec_init(const char* model_dir) {
+facerec* facerec_init(const char* model_dir, const char* sp_name) {
+	facerec* rec = (facerec*)calloc(1, sizeof(facerec));
+	try {
+		std::string dir = model_dir;
+		FaceRec* cls = new FaceRec(
+			file_model(dir + "/" + sp_name),
+			file_model(dir + "/dlib_face_recognition_resnet_model_v1.dat"),
+			file_model(dir + "/mmod_human_face_detector.dat")
+		);
+		rec->cls = (void*)cls;
+	} catch(serialization_error& e) {
+		rec->err_str = strdup(e.what());
+		rec->err_code = SERIALIZATION_ERROR;
+	} catch (std::exception& e) {
+		rec->err_str = strdup(e.what());
+		rec->err_code = UNKNOWN_ERROR;
+	}
+	return rec;
+}
+
+facerec* facerec_init_mem(
+	const char* sp_name,
+	const uint8_t* sp_data, int sp_len,
+	const uint8_t* net_data, int net_len,
+	const uint8_t* cnn_data, int cnn_len
+) {
 	facerec* rec = (facerec*)calloc(1, sizeof(facerec));
 	try {
-		FaceRec* cls = new FaceRec(model_dir);
+		FaceRec* cls = new FaceRec(
+			mem_model(sp_name, sp_data, sp_len),
+			mem_model("dlib_face_recognition_resnet_model_v1.dat", net_data, net_len),
+			mem_model("mmod_human_face_detector.dat", cnn_data, cnn_len)
+		);
 		rec->cls = (void*)cls;
 	} catch(serialization_error& e) {
 		rec->err_str = strdup(e.what());
@@ -170,12 +366,41 @@ facerec* facerec_init(const char* model_dir) {
 	}
 	return rec;
 }
+
+int facerec_load(facerec* rec) {
+	FaceRec* cls = (FaceRec*)(rec->cls);
+	return cls->Load();
+}
+
+static modelret* add_model(facerec* rec, model_source&& src) {
+	modelret* ret = (modelret*)calloc(1, sizeof(modelret));
+	FaceRec* cls = (FaceRec*)(rec->cls);
+	try {
+		ret->model = cls->AddModel(std::move(src));
+	} catch(serialization_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = SERIALIZATION_ERROR;
+	} catch (std::exception& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = UNKNOWN_ERROR;
+	}
+	return ret;
+}
+
+modelret* facerec_add_model(facerec* rec, const char* path) {
+	return add_model(rec, file_model(path));
+}
+
+modelret* facerec_add_model_mem(facerec* rec, const char* name, const uint8_t* data, int len) {
+	return add_model(rec, mem_model(name, data, len));
+}
+
 void facerec_config(facerec* rec, unsigned long size, double padding, int jittering) {
 	FaceRec* cls = (FaceRec*)(rec->cls);
 	cls->Config(size,padding,jittering);
 }
 
-faceret* facerec_recognize(facerec* rec, const uint8_t* img_data, int len, int max_faces,int type) {
+faceret* facerec_recognize(facerec* rec, const uint8_t* img_data, int len, int max_faces,int type,int model) {
 	faceret* ret = (faceret*)calloc(1, sizeof(faceret));
 	FaceRec* cls = (FaceRec*)(rec->cls);
 	matrix<rgb_pixel> img;
@@ -186,34 +411,97 @@ faceret* facerec_recognize(facerec* rec, const uint8_t* img_data, int len, int m
 	try {
 		// TODO(Kagami): Support more file types?
 		load_mem_jpeg(img, img_data, len);
-		std::tie(rects, descrs, shapes) = cls->Recognize(img, max_faces,type);
+		std::tie(rects, descrs, shapes) = cls->Recognize(img, max_faces,type,model);
 	} catch(image_load_error& e) {
 		ret->err_str = strdup(e.what());
 		ret->err_code = IMAGE_LOAD_ERROR;
 		return ret;
+	} catch(serialization_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = SERIALIZATION_ERROR;
+		return ret;
 	} catch (std::exception& e) {
 		ret->err_str = strdup(e.what());
 		ret->err_code = UNKNOWN_ERROR;
 		return ret;
 	}
-	ret->num_faces = descrs.size();
+	fill_faceret(ret, rects, descrs, shapes);
+	return ret;
+}
+
+faceret* facerec_recognize_rects(facerec* rec, const uint8_t* img_data, int len, const long* c_rects, int num_rects, int model) {
+	faceret* ret = (faceret*)calloc(1, sizeof(faceret));
+	FaceRec* cls = (FaceRec*)(rec->cls);
+	matrix<rgb_pixel> img;
+	std::vector<rectangle> rects;
+	std::vector<descriptor> descrs;
+	std::vector<full_object_detection> shapes;
+
+	rects.reserve(num_rects);
+	for (int i = 0; i < num_rects; i++) {
+		const long* src = c_rects + i * RECT_LEN;
+		rects.push_back(rectangle(src[0], src[1], src[2], src[3]));
+	}
+
+	try {
+		load_mem_jpeg(img, img_data, len);
+		std::tie(descrs, shapes) = cls->Describe(img, rects, model);
+	} catch(image_load_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = IMAGE_LOAD_ERROR;
+		return ret;
+	} catch(serialization_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = SERIALIZATION_ERROR;
+		return ret;
+	} catch (std::exception& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = UNKNOWN_ERROR;
+		return ret;
+	}
+	fill_faceret(ret, rects, descrs, shapes);
+	return ret;
+}
+
+detret* facerec_detect(facerec* rec, const uint8_t* img_data, int len, int type, int landmarks) {
+	detret* ret = (detret*)calloc(1, sizeof(detret));
+	FaceRec* cls = (FaceRec*)(rec->cls);
+	matrix<rgb_pixel> img;
+	std::vector<rect_detection> dets;
+	std::vector<full_object_detection> shapes;
+
+	try {
+		load_mem_jpeg(img, img_data, len);
+		std::tie(dets, shapes) = cls->Detect(img, type, landmarks != 0);
+	} catch(image_load_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = IMAGE_LOAD_ERROR;
+		return ret;
+	} catch(serialization_error& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = SERIALIZATION_ERROR;
+		return ret;
+	} catch (std::exception& e) {
+		ret->err_str = strdup(e.what());
+		ret->err_code = UNKNOWN_ERROR;
+		return ret;
+	}
+	ret->num_faces = dets.size();
 
 	if (ret->num_faces == 0)
 		return ret;
 	ret->rectangles = (long*)malloc(ret->num_faces * RECT_SIZE);
+	ret->scores = (double*)malloc(ret->num_faces * sizeof(double));
 	for (int i = 0; i < ret->num_faces; i++) {
 		long* dst = ret->rectangles + i * RECT_LEN;
-		dst[0] = rects[i].left();
-		dst[1] = rects[i].top();
-		dst[2] = rects[i].right();
-		dst[3] = rects[i].bottom();
-	}
-	ret->descriptors = (float*)malloc(ret->num_faces * DESCR_SIZE);
-	for (int i = 0; i < ret->num_faces; i++) {
-		void* dst = (uint8_t*)(ret->descriptors) + i * DESCR_SIZE;
-		void* src = (void*)&descrs[i](0,0);
-		memcpy(dst, src, DESCR_SIZE);
+		dst[0] = dets[i].rect.left();
+		dst[1] = dets[i].rect.top();
+		dst[2] = dets[i].rect.right();
+		dst[3] = dets[i].rect.bottom();
+		ret->scores[i] = dets[i].detection_confidence;
 	}
+	if (shapes.size() == 0)
+		return ret;
 	ret->num_shapes = shapes[0].num_parts();
 	ret->shapes = (long*)malloc(ret->num_faces * ret->num_shapes * SHAPE_SIZE);
 	for (int i = 0; i < ret->num_faces; i++) {
@@ -283,4 +571,41 @@ static std::vector<matrix<rgb_pixel>> jitter_image(
         crops.push_back(jitter_image(img,rnd));
 
     return crops;
-}
\ No newline at end of file
+}
+
+// Copies recognition results into the plain C structure returned to Go.
+static void fill_faceret(
+	faceret* ret,
+	const std::vector<rectangle>& rects,
+	const std::vector<descriptor>& descrs,
+	const std::vector<full_object_detection>& shapes
+) {
+	ret->num_faces = descrs.size();
+
+	if (ret->num_faces == 0)
+		return;
+	ret->rectangles = (long*)malloc(ret->num_faces * RECT_SIZE);
+	for (int i = 0; i < ret->num_faces; i++) {
+		long* dst = ret->rectangles + i * RECT_LEN;
+		dst[0] = rects[i].left();
+		dst[1] = rects[i].top();
+		dst[2] = rects[i].right();
+		dst[3] = rects[i].bottom();
+	}
+	ret->descriptors = (float*)malloc(ret->num_faces * DESCR_SIZE);
+	for (int i = 0; i < ret->num_faces; i++) {
+		void* dst = (uint8_t*)(ret->descriptors) + i * DESCR_SIZE;
+		void* src = (void*)&descrs[i](0,0);
+		memcpy(dst, src, DESCR_SIZE);
+	}
+	ret->num_shapes = shapes[0].num_parts();
+	ret->shapes = (long*)malloc(ret->num_faces * ret->num_shapes * SHAPE_SIZE);
+	for (int i = 0; i < ret->num_faces; i++) {
+		long* dst = ret->shapes + i * ret->num_shapes * SHAPE_LEN;
+		const auto& shape = shapes[i];
+		for (int j = 0; j < ret->num_shapes; j++) {
+			dst[j*SHAPE_LEN] = shape.part(j).x();
+			dst[j*SHAPE_LEN+1] = shape.part(j).y();
+		}
+	}
+}
@@ -10,6 +10,12 @@ typedef enum {
 	UNKNOWN_ERROR,
 } err_code;
 
+// Required models, as flags of the failures returned by facerec_load.
+typedef enum {
+	SHAPE_PREDICTOR_MODEL = 1,
+	RESNET_MODEL = 2,
+} model_flag;
+
 typedef struct facerec {
 	void* cls;
 	const char* err_str;
@@ -29,8 +35,35 @@ typedef struct faceret {
 	err_code err_code;
 } faceret;
 
-facerec* facerec_init(const char* model_dir);
-faceret* facerec_recognize(facerec* rec, const uint8_t* img_data, int len, int max_faces,int type);
+typedef struct modelret {
+	int model;
+	const char* err_str;
+	err_code err_code;
+} modelret;
+
+typedef struct detret {
+	int num_faces;
+	long* rectangles;
+	double* scores;
+	int num_shapes;
+	long* shapes;
+	const char* err_str;
+	err_code err_code;
+} detret;
+
+facerec* facerec_init(const char* model_dir, const char* sp_name);
+facerec* facerec_init_mem(
+	const char* sp_name,
+	const uint8_t* sp_data, int sp_len,
+	const uint8_t* net_data, int net_len,
+	const uint8_t* cnn_data, int cnn_len
+);
+int facerec_load(facerec* rec);
+faceret* facerec_recognize(facerec* rec, const uint8_t* img_data, int len, int max_faces,int type,int model);
+faceret* facerec_recognize_rects(facerec* rec, const uint8_t* img_data, int len, const long* rects, int num_rects, int model);
+modelret* facerec_add_model(facerec* rec, const char* path);
+modelret* facerec_add_model_mem(facerec* rec, const char* name, const uint8_t* data, int len);
+detret* facerec_detect(facerec* rec, const uint8_t* img_data, int len, int type, int landmarks);
 void facerec_set_samples(facerec* rec, const float* descriptors, const int32_t* cats, int len);
 void facerec_reset_samples(facerec* rec);
 int facerec_classify(facerec* rec, const float* descriptor, float tolerance);
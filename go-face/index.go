@@ -0,0 +1,434 @@
+package face
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// IndexOptions configures an Index. Zero values are replaced by defaults
+// suitable for 128-d face descriptors.
+type IndexOptions struct {
+	// M is the number of neighbors linked to every node, twice as many on
+	// the bottom layer.
+	M int
+	// EfConstruction is the size of the candidate list when inserting.
+	EfConstruction int
+	// EfSearch is the size of the candidate list when searching, bigger
+	// values give better recall at the cost of speed.
+	EfSearch int
+	Metric   Metric
+	// Encoding of the indexed descriptors, distances are computed on the
+	// encoded form.
+	Encoding Encoding
+}
+
+// An Index is an approximate nearest neighbor index over descriptors based
+// on Hierarchical Navigable Small World graphs (Malkov & Yashunin). It
+// supports incremental inserts and deletes and can be saved alongside the
+// dataset. Thread-safe.
+type Index struct {
+	opt   IndexOptions
+	mu    sync.RWMutex
+	nodes []indexNode
+	// Vectors of the nodes, only the slice of the encoding is used.
+	f32      []Descriptor
+	f16      []Float16Descriptor
+	i8       []Int8Descriptor
+	ids      map[int]int32
+	entry    int32
+	maxLevel int
+	live     int
+	rnd      *rand.Rand
+}
+
+type indexNode struct {
+	ID      int
+	Cat     int32
+	Friends [][]int32
+	Deleted bool
+}
+
+// indexQuery is a descriptor in the encoding of the index.
+type indexQuery struct {
+	f32 Descriptor
+	f16 Float16Descriptor
+	i8  Int8Descriptor
+}
+
+// NewIndex returns an empty index.
+func NewIndex(opt IndexOptions) *Index {
+	if opt.M <= 0 {
+		opt.M = 16
+	}
+	if opt.EfConstruction <= 0 {
+		opt.EfConstruction = 200
+	}
+	if opt.EfSearch <= 0 {
+		opt.EfSearch = 64
+	}
+	return &Index{
+		opt:   opt,
+		ids:   make(map[int]int32),
+		entry: -1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// Len returns the number of samples in the index, deleted ones excluded.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.live
+}
+
+// Insert adds the sample with the given ID and category, replacing the
+// sample with the same ID if any.
+func (ix *Index) Insert(id int, cat int32, d Descriptor) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.insert(id, cat, ix.query(&d))
+	ix.tidy()
+}
+
+// Delete removes the sample with the given ID. Deleted nodes are kept in
+// the graph to preserve its connectivity but never returned, until they
+// outnumber the samples and the graph is rebuilt without them.
+func (ix *Index) Delete(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.delete(id)
+	ix.tidy()
+}
+
+// Compact rebuilds the graph without the deleted nodes, which slow down
+// searches and take space in saved indexes.
+func (ix *Index) Compact() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.compact()
+}
+
+// Sync makes the index hold exactly samples with IDs equal to their
+// positions, inserting and deleting only what differs.
+func (ix *Index) Sync(samples []Descriptor, cats []int32) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for id, pos := range ix.ids {
+		if id >= len(samples) {
+			ix.delete(id)
+			continue
+		}
+		if ix.nodes[pos].Cat != cats[id] || !ix.holds(pos, ix.query(&samples[id])) {
+			ix.delete(id)
+		}
+	}
+	ix.tidy()
+	for id := range samples {
+		if _, ok := ix.ids[id]; !ok {
+			ix.insert(id, cats[id], ix.query(&samples[id]))
+		}
+	}
+}
+
+// Search returns the k samples nearest to q, sorted by distance. The search
+// is widened until k samples which are not deleted are found.
+func (ix *Index) Search(q Descriptor, k int) []Neighbor {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if ix.entry < 0 || k <= 0 {
+		return nil
+	}
+	query := ix.query(&q)
+	ep := ix.entry
+	for l := ix.maxLevel; l > 0; l-- {
+		ep = ix.searchLayer(query, ep, 1, l)[0].node
+	}
+	k = min(k, ix.live)
+	for ef := max(ix.opt.EfSearch, k); ; ef = min(2*ef, len(ix.nodes)) {
+		var neighbors []Neighbor
+		for _, c := range ix.searchLayer(query, ep, ef, 0) {
+			n := &ix.nodes[c.node]
+			if n.Deleted {
+				continue
+			}
+			neighbors = append(neighbors, Neighbor{Index: n.ID, Cat: n.Cat, Distance: c.dist})
+			if len(neighbors) == k {
+				break
+			}
+		}
+		if len(neighbors) == k || ef >= len(ix.nodes) {
+			return neighbors
+		}
+	}
+}
+
+// Save writes the index to w.
+func (ix *Index) Save(w io.Writer) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(indexFile{
+		Options:  ix.opt,
+		Nodes:    ix.nodes,
+		F32:      ix.f32,
+		F16:      ix.f16,
+		I8:       ix.i8,
+		Entry:    ix.entry,
+		MaxLevel: ix.maxLevel,
+	})
+}
+
+// LoadIndex reads an index written by Save.
+func LoadIndex(r io.Reader) (*Index, error) {
+	var f indexFile
+	if err := gob.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	ix := NewIndex(f.Options)
+	ix.nodes = f.Nodes
+	ix.f32, ix.f16, ix.i8 = f.F32, f.F16, f.I8
+	ix.entry = f.Entry
+	ix.maxLevel = f.MaxLevel
+	for pos, n := range ix.nodes {
+		if !n.Deleted {
+			ix.ids[n.ID] = int32(pos)
+			ix.live++
+		}
+	}
+	return ix, nil
+}
+
+type indexFile struct {
+	Options  IndexOptions
+	Nodes    []indexNode
+	F32      []Descriptor
+	F16      []Float16Descriptor
+	I8       []Int8Descriptor
+	Entry    int32
+	MaxLevel int
+}
+
+func (ix *Index) delete(id int) {
+	pos, ok := ix.ids[id]
+	if !ok {
+		return
+	}
+	ix.nodes[pos].Deleted = true
+	delete(ix.ids, id)
+	ix.live--
+}
+
+// tidy compacts the graph once deleted nodes outnumber the samples.
+func (ix *Index) tidy() {
+	if len(ix.nodes)-ix.live > ix.live {
+		ix.compact()
+	}
+}
+
+// compact inserts the samples in a new graph, in the order of their IDs.
+func (ix *Index) compact() {
+	ids := make([]int, 0, ix.live)
+	for id := range ix.ids {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	nodes, queries := make([]indexNode, len(ids)), make([]*indexQuery, len(ids))
+	for i, id := range ids {
+		pos := ix.ids[id]
+		nodes[i], queries[i] = ix.nodes[pos], ix.nodeQuery(pos)
+	}
+	ix.nodes, ix.f32, ix.f16, ix.i8 = nil, nil, nil, nil
+	ix.ids = make(map[int]int32, len(ids))
+	ix.entry, ix.maxLevel, ix.live = -1, 0, 0
+	for i, n := range nodes {
+		ix.insert(n.ID, n.Cat, queries[i])
+	}
+}
+
+func (ix *Index) insert(id int, cat int32, query *indexQuery) {
+	ix.delete(id)
+	level := int(-math.Log(1-ix.rnd.Float64()) / math.Log(float64(ix.opt.M)))
+	pos := int32(len(ix.nodes))
+	ix.nodes = append(ix.nodes, indexNode{
+		ID:      id,
+		Cat:     cat,
+		Friends: make([][]int32, level+1),
+	})
+	switch ix.opt.Encoding {
+	case Float16:
+		ix.f16 = append(ix.f16, query.f16)
+	case Int8:
+		ix.i8 = append(ix.i8, query.i8)
+	default:
+		ix.f32 = append(ix.f32, query.f32)
+	}
+	ix.ids[id] = pos
+	ix.live++
+	if ix.entry < 0 {
+		ix.entry, ix.maxLevel = pos, level
+		return
+	}
+
+	ep := ix.entry
+	for l := ix.maxLevel; l > level; l-- {
+		ep = ix.searchLayer(query, ep, 1, l)[0].node
+	}
+	for l := min(level, ix.maxLevel); l >= 0; l-- {
+		candidates := ix.searchLayer(query, ep, ix.opt.EfConstruction, l)
+		ep = candidates[0].node
+		maxFriends := ix.maxFriends(l)
+		for i := 0; i < len(candidates) && i < ix.opt.M; i++ {
+			friend := candidates[i].node
+			ix.nodes[pos].Friends[l] = append(ix.nodes[pos].Friends[l], friend)
+			ix.link(friend, pos, l, maxFriends)
+		}
+	}
+	if level > ix.maxLevel {
+		ix.entry, ix.maxLevel = pos, level
+	}
+}
+
+func (ix *Index) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * ix.opt.M
+	}
+	return ix.opt.M
+}
+
+// link adds to as a friend of from on the level, keeping only the nearest
+// maxFriends friends.
+func (ix *Index) link(from, to int32, level, maxFriends int) {
+	n := &ix.nodes[from]
+	n.Friends[level] = append(n.Friends[level], to)
+	if len(n.Friends[level]) <= maxFriends {
+		return
+	}
+	query := ix.nodeQuery(from)
+	friends := make([]indexCandidate, len(n.Friends[level]))
+	for i, f := range n.Friends[level] {
+		friends[i] = indexCandidate{f, ix.distance(query, f)}
+	}
+	sort.Slice(friends, func(i, j int) bool { return friends[i].dist < friends[j].dist })
+	n.Friends[level] = n.Friends[level][:maxFriends]
+	for i := range n.Friends[level] {
+		n.Friends[level][i] = friends[i].node
+	}
+}
+
+// query encodes d like the indexed descriptors.
+func (ix *Index) query(d *Descriptor) *indexQuery {
+	q := &indexQuery{}
+	switch ix.opt.Encoding {
+	case Float16:
+		q.f16 = QuantizeFloat16(*d)
+	case Int8:
+		q.i8 = QuantizeInt8(*d)
+	default:
+		q.f32 = *d
+	}
+	return q
+}
+
+// nodeQuery returns the descriptor of the node as a query.
+func (ix *Index) nodeQuery(node int32) *indexQuery {
+	q := &indexQuery{}
+	switch ix.opt.Encoding {
+	case Float16:
+		q.f16 = ix.f16[node]
+	case Int8:
+		q.i8 = ix.i8[node]
+	default:
+		q.f32 = ix.f32[node]
+	}
+	return q
+}
+
+// holds reports whether the node has the descriptor of q.
+func (ix *Index) holds(node int32, q *indexQuery) bool {
+	switch ix.opt.Encoding {
+	case Float16:
+		return ix.f16[node] == q.f16
+	case Int8:
+		return ix.i8[node] == q.i8
+	default:
+		return ix.f32[node] == q.f32
+	}
+}
+
+func (ix *Index) distance(q *indexQuery, node int32) float32 {
+	switch ix.opt.Encoding {
+	case Float16:
+		return ix.opt.Metric.DistanceFloat16(&q.f16, &ix.f16[node])
+	case Int8:
+		return ix.opt.Metric.DistanceInt8(&q.i8, &ix.i8[node])
+	default:
+		return ix.opt.Metric.distance(&q.f32, &ix.f32[node])
+	}
+}
+
+type indexCandidate struct {
+	node int32
+	dist float32
+}
+
+// candidateHeap is a min-heap on distance, or a max-heap if far is set.
+type candidateHeap struct {
+	items []indexCandidate
+	far   bool
+}
+
+func (h candidateHeap) Len() int { return len(h.items) }
+func (h candidateHeap) Less(i, j int) bool {
+	if h.far {
+		return h.items[i].dist > h.items[j].dist
+	}
+	return h.items[i].dist < h.items[j].dist
+}
+func (h candidateHeap) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(indexCandidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// searchLayer returns up to ef nodes of the level nearest to q, sorted by
+// distance, starting from ep.
+func (ix *Index) searchLayer(q *indexQuery, ep int32, ef, level int) []indexCandidate {
+	visited := make([]bool, len(ix.nodes))
+	visited[ep] = true
+	start := indexCandidate{ep, ix.distance(q, ep)}
+	candidates := &candidateHeap{items: []indexCandidate{start}}
+	results := &candidateHeap{items: []indexCandidate{start}, far: true}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(indexCandidate)
+		if c.dist > results.items[0].dist && results.Len() >= ef {
+			break
+		}
+		friends := ix.nodes[c.node].Friends
+		if level >= len(friends) {
+			continue
+		}
+		for _, f := range friends[level] {
+			if visited[f] {
+				continue
+			}
+			visited[f] = true
+			dist := ix.distance(q, f)
+			if results.Len() < ef || dist < results.items[0].dist {
+				heap.Push(candidates, indexCandidate{f, dist})
+				heap.Push(results, indexCandidate{f, dist})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	sorted := results.items
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].dist < sorted[j].dist })
+	return sorted
+}
@@ -0,0 +1,144 @@
+package face
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randomDescriptors(n int, seed int64) []Descriptor {
+	rnd := rand.New(rand.NewSource(seed))
+	ds := make([]Descriptor, n)
+	for i := range ds {
+		for j := range ds[i] {
+			ds[i][j] = float32(rnd.NormFloat64())
+		}
+	}
+	return ds
+}
+
+// faceDescriptors returns n descriptors around the identities, spread like
+// face descriptors.
+func faceDescriptors(identities []Descriptor, n int, seed int64) []Descriptor {
+	rnd := rand.New(rand.NewSource(seed))
+	ds := make([]Descriptor, n)
+	for i := range ds {
+		c := &identities[rnd.Intn(len(identities))]
+		for j := range ds[i] {
+			ds[i][j] = 0.1*c[j] + 0.03*float32(rnd.NormFloat64())
+		}
+	}
+	return ds
+}
+
+// byDistance returns the positions of ds sorted by distance to q.
+func byDistance(ds []Descriptor, q Descriptor, m Metric) []int {
+	order := make([]int, len(ds))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return m.Distance(ds[order[i]], q) < m.Distance(ds[order[j]], q)
+	})
+	return order
+}
+
+func TestIndexSearchDeleted(t *testing.T) {
+	ds := randomDescriptors(400, 1)
+	q := ds[0]
+	order := byDistance(ds, q, SquaredEuclidean)
+
+	tests := []struct {
+		name    string
+		deleted int
+		nodes   int
+	}{
+		// More deleted nodes than EfSearch around the query.
+		{"tombstones", 190, 400},
+		// The 201st deletion makes deleted nodes outnumber the samples,
+		// the graph is rebuilt without them.
+		{"compacted", 201, 199},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := NewIndex(IndexOptions{})
+			cats := make([]int32, len(ds))
+			ix.Sync(ds, cats)
+			for _, id := range order[:tt.deleted] {
+				ix.Delete(id)
+			}
+			if len(ix.nodes) != tt.nodes || ix.Len() != len(ds)-tt.deleted {
+				t.Fatalf("%d nodes, %d samples; want %d, %d", len(ix.nodes), ix.Len(), tt.nodes, len(ds)-tt.deleted)
+			}
+			got := ix.Search(q, 10)
+			if len(got) != 10 {
+				t.Fatalf("found %d samples, want 10", len(got))
+			}
+			for i, n := range got {
+				if n.Index != order[tt.deleted+i] {
+					t.Errorf("neighbor %d is %d, want %d", i, n.Index, order[tt.deleted+i])
+				}
+			}
+		})
+	}
+}
+
+func TestIndexCompact(t *testing.T) {
+	ds := randomDescriptors(100, 2)
+	for _, enc := range []Encoding{Float32, Float16, Int8} {
+		ix := NewIndex(IndexOptions{Encoding: enc})
+		ix.Sync(ds, make([]int32, len(ds)))
+		for id := 0; id < len(ds); id += 3 {
+			ix.Delete(id)
+		}
+		before := ix.Search(ds[1], 5)
+		ix.Compact()
+		if len(ix.nodes) != ix.Len() {
+			t.Fatalf("encoding %v: %d nodes for %d samples", enc, len(ix.nodes), ix.Len())
+		}
+		after := ix.Search(ds[1], 5)
+		for i := range before {
+			if before[i] != after[i] {
+				t.Errorf("encoding %v: neighbor %d is %+v, was %+v", enc, i, after[i], before[i])
+			}
+		}
+	}
+}
+
+func TestIndexRecall(t *testing.T) {
+	identities := randomDescriptors(100, 4)
+	samples := faceDescriptors(identities, 1000, 5)
+	queries := faceDescriptors(identities, 50, 6)
+	const k = 10
+
+	tests := []struct {
+		encoding  Encoding
+		metric    Metric
+		minRecall float64
+	}{
+		{Float32, SquaredEuclidean, 0.95},
+		{Float32, Cosine, 0.95},
+		{Float16, SquaredEuclidean, 0.95},
+		{Int8, SquaredEuclidean, 0.9},
+		{Int8, Cosine, 0.9},
+	}
+	for _, tt := range tests {
+		ix := NewIndex(IndexOptions{Encoding: tt.encoding, Metric: tt.metric})
+		ix.Sync(samples, make([]int32, len(samples)))
+		found := 0
+		for _, q := range queries {
+			exact := make(map[int]bool, k)
+			for _, i := range byDistance(samples, q, tt.metric)[:k] {
+				exact[i] = true
+			}
+			for _, n := range ix.Search(q, k) {
+				if exact[n.Index] {
+					found++
+				}
+			}
+		}
+		if recall := float64(found) / float64(k*len(queries)); recall < tt.minRecall {
+			t.Errorf("encoding %v, metric %v: recall %.3f, want at least %.2f", tt.encoding, tt.metric, recall, tt.minRecall)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package face
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+
+	"github.com/oarkflow/imaging/imag"
+	"github.com/oarkflow/imaging/imgsz"
+)
+
+// Limits bounds the images accepted for decoding so that oversized uploads
+// and decompression bombs are rejected before they are decoded. Zero value
+// of any field disables the corresponding check.
+type Limits struct {
+	// MaxFileSize is the maximum size of the encoded image in bytes.
+	MaxFileSize int64
+	// MaxPixels is the maximum number of pixels (width*height).
+	MaxPixels int64
+	// MaxBytes is the maximum memory taken by the decoded image, counting
+	// 4 bytes per pixel.
+	MaxBytes int64
+	// MaxDimension is the maximum width or height passed to the detector.
+	// Bigger images are downscaled before detection and the found
+	// rectangles and shapes are mapped back to the original scale.
+	MaxDimension int
+}
+
+// DefaultLimits are used by new recognizers until SetLimits is called.
+var DefaultLimits = Limits{
+	MaxFileSize: 50 << 20,
+	MaxPixels:   50_000_000,
+}
+
+// CheckFileSize returns ImageTooLargeError if the encoded image size
+// exceeds MaxFileSize.
+func (l Limits) CheckFileSize(size int64) error {
+	if l.MaxFileSize > 0 && size > l.MaxFileSize {
+		return ImageTooLargeError(fmt.Sprintf("image file size %d exceeds %d bytes", size, l.MaxFileSize))
+	}
+	return nil
+}
+
+// CheckSize returns ImageTooLargeError if the image dimensions exceed
+// MaxPixels or MaxBytes.
+func (l Limits) CheckSize(size imgsz.Size) error {
+	if size.Width <= 0 || size.Height <= 0 {
+		return ImageLoadError(fmt.Sprintf("invalid image size %dx%d", size.Width, size.Height))
+	}
+	pixels := int64(size.Width) * int64(size.Height)
+	if l.MaxPixels > 0 && pixels > l.MaxPixels {
+		return ImageTooLargeError(fmt.Sprintf("image size %dx%d exceeds %d pixels", size.Width, size.Height, l.MaxPixels))
+	}
+	if l.MaxBytes > 0 && pixels*4 > l.MaxBytes {
+		return ImageTooLargeError(fmt.Sprintf("image size %dx%d exceeds %d bytes when decoded", size.Width, size.Height, l.MaxBytes))
+	}
+	return nil
+}
+
+// Decode checks the image dimensions from its header and decodes it with
+// auto orientation only if they are within the limits.
+func (l Limits) Decode(r io.Reader) (image.Image, error) {
+	var header bytes.Buffer
+	size, err := decodeSize(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if err := l.CheckSize(size); err != nil {
+		return nil, err
+	}
+	return imag.Decode(io.MultiReader(&header, r), imag.AutoOrientation(true))
+}
+
+// Open checks the file size and the image dimensions and decodes the
+// image file with auto orientation.
+func (l Limits) Open(imgPath string) (image.Image, error) {
+	fd, err := os.Open(imgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.CheckFileSize(info.Size()); err != nil {
+		return nil, err
+	}
+	return l.Decode(fd)
+}
+
+// prepare checks the image data against the limits. The image is decoded
+// with auto orientation and re-encoded to JPEG, the only format understood
+// by the C layer, if it has to be downscaled or carries an EXIF orientation,
+// so that coordinates are always in the frame of the oriented image. It
+// returns the data along with the factor to map found coordinates back to
+// that frame.
+func (l Limits) prepare(imgData []byte) ([]byte, float64, error) {
+	if len(imgData) == 0 {
+		return nil, 1, ImageLoadError("Empty image")
+	}
+	if err := l.CheckFileSize(int64(len(imgData))); err != nil {
+		return nil, 1, err
+	}
+	size, err := decodeSize(bytes.NewReader(imgData))
+	if err != nil {
+		return nil, 1, err
+	}
+	if err := l.CheckSize(size); err != nil {
+		return nil, 1, err
+	}
+	if !l.needsDownscale(size) && imag.ReadOrientation(bytes.NewReader(imgData)) <= 1 {
+		return imgData, 1, nil
+	}
+	img, err := imag.Decode(bytes.NewReader(imgData), imag.AutoOrientation(true))
+	if err != nil {
+		return nil, 1, err
+	}
+	return l.encode(img)
+}
+
+// encode downscales the image to fit MaxDimension and encodes it to JPEG.
+func (l Limits) encode(img image.Image) ([]byte, float64, error) {
+	img, factor := l.downscale(img)
+	var b bytes.Buffer
+	if err := imag.Encode(&b, img, imag.JPEG); err != nil {
+		return nil, 1, err
+	}
+	return b.Bytes(), factor, nil
+}
+
+// decodeSize reads the image dimensions using imgsz, falling back to the
+// registered standard library decoders for other formats.
+func decodeSize(r io.Reader) (imgsz.Size, error) {
+	var header bytes.Buffer
+	size, _, err := imgsz.DecodeSize(io.TeeReader(r, &header))
+	if err != imgsz.ErrFormat {
+		return size, err
+	}
+	cfg, _, err := image.DecodeConfig(io.MultiReader(&header, r))
+	if err != nil {
+		return imgsz.Size{}, ImageLoadError(err.Error())
+	}
+	return imgsz.Size{Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// downscale resizes the image to fit MaxDimension. It returns the factor to
+// multiply the coordinates found on the resized image by.
+func (l Limits) downscale(img image.Image) (image.Image, float64) {
+	b := img.Bounds()
+	if l.MaxDimension <= 0 || (b.Dx() <= l.MaxDimension && b.Dy() <= l.MaxDimension) {
+		return img, 1
+	}
+	if b.Dx() >= b.Dy() {
+		img = imag.Resize(img, l.MaxDimension, 0, imag.Linear)
+	} else {
+		img = imag.Resize(img, 0, l.MaxDimension, imag.Linear)
+	}
+	return img, float64(b.Dx()) / float64(img.Bounds().Dx())
+}
+
+// needsDownscale reports whether an image of the given size would be
+// resized by downscale.
+func (l Limits) needsDownscale(size imgsz.Size) bool {
+	return l.MaxDimension > 0 && (size.Width > l.MaxDimension || size.Height > l.MaxDimension)
+}
+
+func scalePoint(p image.Point, factor float64) image.Point {
+	return image.Point{
+		X: int(math.Round(float64(p.X) * factor)),
+		Y: int(math.Round(float64(p.Y) * factor)),
+	}
+}
+
+func scaleRect(r image.Rectangle, factor float64) image.Rectangle {
+	return image.Rectangle{Min: scalePoint(r.Min, factor), Max: scalePoint(r.Max, factor)}
+}
+
+func scaleFaces(faces []Face, factor float64) []Face {
+	if factor == 1 {
+		return faces
+	}
+	for i := range faces {
+		faces[i].Rectangle = scaleRect(faces[i].Rectangle, factor)
+		for j := range faces[i].Shapes {
+			faces[i].Shapes[j] = scalePoint(faces[i].Shapes[j], factor)
+		}
+	}
+	return faces
+}
+
+func scaleDetections(dets []Detection, factor float64) []Detection {
+	if factor == 1 {
+		return dets
+	}
+	for i := range dets {
+		dets[i].Rectangle = scaleRect(dets[i].Rectangle, factor)
+		for j := range dets[i].Shapes {
+			dets[i].Shapes[j] = scalePoint(dets[i].Shapes[j], factor)
+		}
+	}
+	return dets
+}
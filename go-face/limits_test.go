@@ -0,0 +1,137 @@
+package face
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/oarkflow/imaging/imag"
+)
+
+// exifJPEG encodes img to JPEG with an EXIF orientation tag.
+func exifJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A big-endian TIFF header and an IFD holding the orientation only.
+	var exif bytes.Buffer
+	exif.WriteString("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08")
+	binary.Write(&exif, binary.BigEndian, []uint16{1, 0x0112, 3, 0, 1, orientation, 0, 0, 0})
+	var out bytes.Buffer
+	out.Write(b.Bytes()[:2])
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(exif.Len()+2))
+	out.Write(exif.Bytes())
+	out.Write(b.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestLimitsPrepareOrientation(t *testing.T) {
+	// A landscape picture with a white left half, stored rotated: it is
+	// displayed as a portrait one with a white top half.
+	img := image.NewGray(image.Rect(0, 0, 80, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	data := exifJPEG(t, img, 6)
+
+	tests := []struct {
+		name   string
+		limits Limits
+		size   image.Point
+		factor float64
+	}{
+		{"below limit", Limits{}, image.Pt(40, 80), 1},
+		{"above limit", Limits{MaxDimension: 40}, image.Pt(20, 40), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, factor, err := tt.limits.prepare(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := imag.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := got.Bounds().Size(); size != tt.size || factor != tt.factor {
+				t.Fatalf("got %v by %v, want %v by %v", size, factor, tt.size, tt.factor)
+			}
+			// The C layer ignores EXIF: the data must be oriented already.
+			top := color.GrayModel.Convert(got.At(tt.size.X/2, tt.size.Y/4)).(color.Gray).Y
+			bottom := color.GrayModel.Convert(got.At(tt.size.X/2, tt.size.Y*3/4)).(color.Gray).Y
+			if top < 200 || bottom > 50 {
+				t.Errorf("got top %d and bottom %d, want a white top half", top, bottom)
+			}
+		})
+	}
+}
+
+func TestLimitsPrepareUnchanged(t *testing.T) {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, 80, 40)), nil); err != nil {
+		t.Fatal(err)
+	}
+	out, factor, err := Limits{MaxDimension: 80}.prepare(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, b.Bytes()) || factor != 1 {
+		t.Errorf("got %d bytes by %v, want the %d bytes of the image by 1", len(out), factor, b.Len())
+	}
+}
+
+func TestScaleRectRoundTrip(t *testing.T) {
+	// Rectangles given on the original image are scaled down to the
+	// downscaled one and the faces found back up.
+	tests := []struct {
+		name   string
+		rect   image.Rectangle
+		factor float64
+	}{
+		{"unscaled", image.Rect(10, 20, 110, 140), 1},
+		{"halved", image.Rect(10, 20, 110, 140), 2},
+		{"odd factor", image.Rect(13, 27, 251, 333), 3.7},
+		{"negative origin", image.Rect(-15, -5, 40, 60), 2.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaled := scaleRect(tt.rect, 1/tt.factor)
+			faces := scaleFaces([]Face{{Rectangle: scaled}}, tt.factor)
+			got := faces[0].Rectangle
+			// Rounding to the downscaled pixels loses up to half a factor.
+			tolerance := int(math.Ceil(tt.factor / 2))
+			for _, d := range []int{got.Min.X - tt.rect.Min.X, got.Min.Y - tt.rect.Min.Y, got.Max.X - tt.rect.Max.X, got.Max.Y - tt.rect.Max.Y} {
+				if d > tolerance || d < -tolerance {
+					t.Fatalf("got %v back, want %v within %d pixels", got, tt.rect, tolerance)
+				}
+			}
+		})
+	}
+}
+
+func TestScaleDetections(t *testing.T) {
+	dets := []Detection{{
+		Rectangle:  image.Rect(10, 20, 30, 40),
+		Confidence: 0.8,
+		Shapes:     []image.Point{{15, 25}, {25, 35}},
+	}}
+	got := scaleDetections(dets, 2.5)
+	want := Detection{
+		Rectangle:  image.Rect(25, 50, 75, 100),
+		Confidence: 0.8,
+		Shapes:     []image.Point{{38, 63}, {63, 88}},
+	}
+	if got[0].Rectangle != want.Rectangle || got[0].Confidence != want.Confidence ||
+		got[0].Shapes[0] != want.Shapes[0] || got[0].Shapes[1] != want.Shapes[1] {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+}
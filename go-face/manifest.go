@@ -0,0 +1,109 @@
+package face
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ModelChecksum holds the expected size and SHA-256 of a model file. Zero
+// Size or empty SHA256 skip the corresponding check.
+type ModelChecksum struct {
+	Size   int64
+	SHA256 string
+}
+
+// Manifest maps model file names to their expected checksums. Files not
+// listed in the manifest are not verified.
+type Manifest map[string]ModelChecksum
+
+// DefaultManifest is used by NewRecognizer to catch truncated or
+// corrupted model files. The shape predictors and the ResNet model of the
+// dlib releases are pinned by size, and deserialized by the constructors
+// which reports the corrupted ones. Pass a custom manifest to
+// NewRecognizerWithManifest to pin other releases of the models or your own
+// trained detectors, e.g. built with NewManifest from copies of the models
+// known to be good.
+var DefaultManifest = Manifest{
+	ShapePredictorModel:   {Size: 9150489},
+	ShapePredictor68Model: {Size: 99693937},
+	ResNetModel:           {Size: 22466066},
+	CNNModel: {
+		Size:   729940,
+		SHA256: "be467b1a76f482693de3b0f6a1ff91d092319be71523d4d4b0628f6a53fcb87a",
+	},
+}
+
+// NewManifest returns the checksums of the model files of dir, all its
+// regular files if names is empty.
+func NewManifest(dir string, names ...string) (Manifest, error) {
+	if len(names) == 0 {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	m := make(Manifest, len(names))
+	for _, name := range names {
+		fd, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		size, err := io.Copy(h, fd)
+		fd.Close()
+		if err != nil {
+			return nil, err
+		}
+		m[name] = ModelChecksum{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}
+	}
+	return m, nil
+}
+
+// Verify checks the model read from r against the manifest entry for name.
+func (m Manifest) Verify(name string, r io.Reader) error {
+	sum, ok := m[name]
+	if !ok {
+		return nil
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+	if sum.Size > 0 && size != sum.Size {
+		return fmt.Errorf("%s: size %d, expected %d", name, size, sum.Size)
+	}
+	if sum.SHA256 != "" && hex.EncodeToString(h.Sum(nil)) != sum.SHA256 {
+		return fmt.Errorf("%s: SHA-256 mismatch", name)
+	}
+	return nil
+}
+
+// VerifyFile Same as Verify but reads the model from path, the manifest
+// entry is looked up by the base name of the file.
+func (m Manifest) VerifyFile(path string) error {
+	if _, ok := m[filepath.Base(path)]; !ok {
+		return nil
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return m.Verify(filepath.Base(path), fd)
+}
+
+// VerifyData Same as Verify but for a model already read into memory.
+func (m Manifest) VerifyData(name string, data []byte) error {
+	return m.Verify(name, bytes.NewReader(data))
+}
@@ -0,0 +1,50 @@
+package face
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewManifestVerify(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "model.dat"), []byte("serialized model"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["model.dat"].Size != 16 || len(m["model.dat"].SHA256) != 64 {
+		t.Fatalf("unexpected entry %+v", m["model.dat"])
+	}
+
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"model.dat", "serialized model", true},
+		{"model.dat", "serialized", false},
+		{"model.dat", "serialized modeL", false},
+		{"other.dat", "anything", true},
+	}
+	for _, tt := range tests {
+		if err := m.VerifyData(tt.name, []byte(tt.data)); (err == nil) != tt.ok {
+			t.Errorf("VerifyData(%q, %q) = %v", tt.name, tt.data, err)
+		}
+	}
+}
+
+func TestDefaultManifestPinsModels(t *testing.T) {
+	for _, name := range []string{ShapePredictorModel, ShapePredictor68Model, ResNetModel, CNNModel} {
+		sum, ok := DefaultManifest[name]
+		if !ok || sum.Size <= 0 {
+			t.Errorf("%s not pinned: %+v", name, sum)
+		}
+	}
+	// A truncated download is caught before deserializing.
+	if err := DefaultManifest.VerifyData(ResNetModel, make([]byte, 1024)); err == nil {
+		t.Error("truncated ResNet model verified")
+	}
+}
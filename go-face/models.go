@@ -0,0 +1,147 @@
+package face
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Model file names looked up in the model directory.
+const (
+	ShapePredictorModel = "shape_predictor_5_face_landmarks.dat"
+	// ShapePredictor68Model is the alternative shape predictor with 68
+	// landmarks, outlining the eyes, nose and mouth, see
+	// NewRecognizerWithShapePredictor.
+	ShapePredictor68Model = "shape_predictor_68_face_landmarks.dat"
+	ResNetModel           = "dlib_face_recognition_resnet_model_v1.dat"
+	CNNModel              = "mmod_human_face_detector.dat"
+)
+
+// DefaultModel is the ID of the embedding model loaded from ResNetModel.
+// Other models are registered with Recognizer.AddModel.
+const DefaultModel = "dlib_face_recognition_resnet_model_v1"
+
+// checkModelDir reports the required model files that are missing in dir
+// and the model files that are empty or don't match the manifest. The shape
+// predictor and the ResNet model are needed for recognition, the CNN
+// detector model is only loaded when a CNN method is called.
+func checkModelDir(dir string, manifest Manifest, shapePredictor string) error {
+	merr := &ModelError{Dir: dir}
+	for _, name := range []string{shapePredictor, ResNetModel, CNNModel} {
+		required := name != CNNModel
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			if required {
+				merr.Missing = append(merr.Missing, name)
+			}
+		case err != nil:
+			return err
+		case !info.Mode().IsRegular() || info.Size() == 0:
+			merr.Corrupt = append(merr.Corrupt, name)
+		case manifest.VerifyFile(path) != nil:
+			merr.Corrupt = append(merr.Corrupt, name)
+		}
+	}
+	if len(merr.Missing) > 0 || len(merr.Corrupt) > 0 {
+		return merr
+	}
+	return nil
+}
+
+// ModelData holds serialized dlib models, e.g. read from an embed.FS or
+// downloaded at startup. CNN may be left nil if CNN methods are not used.
+type ModelData struct {
+	// ShapePredictor is the model of the ShapePredictorName file.
+	ShapePredictor []byte
+	// ShapePredictorName is ShapePredictorModel if empty, or
+	// ShapePredictor68Model.
+	ShapePredictorName string
+	ResNet             []byte
+	CNN                []byte
+	// Manifest to verify the models against, DefaultManifest if nil.
+	Manifest Manifest
+}
+
+// ReadModelData reads the model files from dir of fsys. Required models
+// that are missing or empty are reported with *ModelError, the CNN model is
+// optional.
+func ReadModelData(fsys fs.FS, dir string) (models ModelData, err error) {
+	return ReadModelDataWithShapePredictor(fsys, dir, ShapePredictorModel)
+}
+
+// ReadModelDataWithShapePredictor Same as ReadModelData but reads the shape
+// predictor file shapePredictor of dir, e.g. ShapePredictor68Model.
+func ReadModelDataWithShapePredictor(fsys fs.FS, dir string, shapePredictor string) (models ModelData, err error) {
+	merr := &ModelError{Dir: dir}
+	read := func(name string, required bool) []byte {
+		data, rerr := fs.ReadFile(fsys, path.Join(dir, name))
+		switch {
+		case errors.Is(rerr, fs.ErrNotExist):
+			if required {
+				merr.Missing = append(merr.Missing, name)
+			}
+		case rerr != nil:
+			if err == nil {
+				err = rerr
+			}
+		case len(data) == 0:
+			merr.Corrupt = append(merr.Corrupt, name)
+		}
+		return data
+	}
+	models.ShapePredictor = read(shapePredictor, true)
+	models.ShapePredictorName = shapePredictor
+	models.ResNet = read(ResNetModel, true)
+	models.CNN = read(CNNModel, false)
+	if err != nil {
+		return
+	}
+	if len(merr.Missing) > 0 || len(merr.Corrupt) > 0 {
+		err = merr
+	}
+	return
+}
+
+// check reports the required models that were not provided and the models
+// that don't match the manifest.
+func (m ModelData) check() error {
+	manifest := m.Manifest
+	if manifest == nil {
+		manifest = DefaultManifest
+	}
+	merr := &ModelError{Dir: "memory"}
+	for _, model := range []struct {
+		name     string
+		data     []byte
+		required bool
+	}{
+		{m.shapePredictorName(), m.ShapePredictor, true},
+		{ResNetModel, m.ResNet, true},
+		{CNNModel, m.CNN, false},
+	} {
+		switch {
+		case len(model.data) == 0:
+			if model.required {
+				merr.Missing = append(merr.Missing, model.name)
+			}
+		case manifest.VerifyData(model.name, model.data) != nil:
+			merr.Corrupt = append(merr.Corrupt, model.name)
+		}
+	}
+	if len(merr.Missing) > 0 || len(merr.Corrupt) > 0 {
+		return merr
+	}
+	return nil
+}
+
+// shapePredictorName returns the file name of ShapePredictor.
+func (m ModelData) shapePredictorName() string {
+	if m.ShapePredictorName == "" {
+		return ShapePredictorModel
+	}
+	return m.ShapePredictorName
+}
@@ -0,0 +1,214 @@
+package face
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadModelDataShapePredictor(t *testing.T) {
+	fsys := fstest.MapFS{
+		"models/" + ShapePredictor68Model: {Data: []byte("68 landmarks")},
+		"models/" + ResNetModel:           {Data: []byte("resnet")},
+	}
+	good := Manifest{ShapePredictor68Model: {Size: 12}}
+	bad := Manifest{ShapePredictor68Model: {Size: 5}}
+
+	tests := []struct {
+		name           string
+		shapePredictor string
+		manifest       Manifest
+		missing        []string
+		corrupt        []string
+	}{
+		{"68 points", ShapePredictor68Model, good, nil, nil},
+		{"68 points corrupt", ShapePredictor68Model, bad, nil, []string{ShapePredictor68Model}},
+		{"5 points missing", ShapePredictorModel, good, []string{ShapePredictorModel}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, err := ReadModelDataWithShapePredictor(fsys, "models", tt.shapePredictor)
+			if err == nil {
+				models.Manifest = tt.manifest
+				err = models.check()
+			}
+			var merr *ModelError
+			if err != nil && !errors.As(err, &merr) {
+				t.Fatal(err)
+			}
+			if merr == nil {
+				merr = &ModelError{}
+			}
+			if !reflect.DeepEqual(merr.Missing, tt.missing) || !reflect.DeepEqual(merr.Corrupt, tt.corrupt) {
+				t.Errorf("missing %v, corrupt %v; want %v, %v", merr.Missing, merr.Corrupt, tt.missing, tt.corrupt)
+			}
+		})
+	}
+}
+
+func TestCheckModelDir(t *testing.T) {
+	all := map[string]string{
+		ShapePredictorModel: "5 landmarks",
+		ResNetModel:         "resnet",
+		CNNModel:            "cnn",
+	}
+	without := func(name string) map[string]string {
+		files := make(map[string]string)
+		for n, data := range all {
+			if n != name {
+				files[n] = data
+			}
+		}
+		return files
+	}
+	with := func(name, data string) map[string]string {
+		files := without(name)
+		files[name] = data
+		return files
+	}
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		dirs           []string
+		shapePredictor string
+		manifest       Manifest
+		missing        []string
+		corrupt        []string
+	}{
+		{"complete", all, nil, ShapePredictorModel, nil, nil, nil},
+		{"no CNN", without(CNNModel), nil, ShapePredictorModel, nil, nil, nil},
+		{"no ResNet", without(ResNetModel), nil, ShapePredictorModel, nil, []string{ResNetModel}, nil},
+		{"no 68 points", all, nil, ShapePredictor68Model, nil, []string{ShapePredictor68Model}, nil},
+		{"empty", with(ShapePredictorModel, ""), nil, ShapePredictorModel, nil, nil, []string{ShapePredictorModel}},
+		{"empty CNN", with(CNNModel, ""), nil, ShapePredictorModel, nil, nil, []string{CNNModel}},
+		{"directory", without(ResNetModel), []string{ResNetModel}, ShapePredictorModel, nil, nil, []string{ResNetModel}},
+		{"manifest", all, nil, ShapePredictorModel, Manifest{ResNetModel: {Size: 7}}, nil, []string{ResNetModel}},
+		{"both", with(ResNetModel, ""), nil, ShapePredictor68Model, nil, []string{ShapePredictor68Model}, []string{ResNetModel}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tt.dirs {
+				if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkModelDir(dir, tt.manifest, tt.shapePredictor)
+			var merr *ModelError
+			if err != nil && !errors.As(err, &merr) {
+				t.Fatal(err)
+			}
+			if merr == nil {
+				merr = &ModelError{}
+			} else if merr.Dir != dir {
+				t.Errorf("error of dir %q, want %q", merr.Dir, dir)
+			}
+			if !reflect.DeepEqual(merr.Missing, tt.missing) || !reflect.DeepEqual(merr.Corrupt, tt.corrupt) {
+				t.Errorf("missing %v, corrupt %v; want %v, %v", merr.Missing, merr.Corrupt, tt.missing, tt.corrupt)
+			}
+		})
+	}
+}
+
+func TestReadModelData(t *testing.T) {
+	complete := fstest.MapFS{
+		"models/" + ShapePredictorModel: {Data: []byte("5 landmarks")},
+		"models/" + ResNetModel:         {Data: []byte("resnet")},
+		"models/" + CNNModel:            {Data: []byte("cnn")},
+	}
+	without := func(name string) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for n, f := range complete {
+			if n != "models/"+name {
+				fsys[n] = f
+			}
+		}
+		return fsys
+	}
+	empty := without(ResNetModel)
+	empty["models/"+ResNetModel] = &fstest.MapFile{}
+	unreadable := without(ResNetModel)
+	unreadable["models/"+ResNetModel+"/part"] = &fstest.MapFile{Data: []byte("resnet")}
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		cnn     bool
+		missing []string
+		corrupt []string
+		ok      bool
+	}{
+		{"complete", complete, true, nil, nil, true},
+		{"no CNN", without(CNNModel), false, nil, nil, true},
+		{"no shape predictor", without(ShapePredictorModel), true, []string{ShapePredictorModel}, nil, false},
+		{"empty ResNet", empty, true, nil, []string{ResNetModel}, false},
+		{"unreadable", unreadable, true, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, err := ReadModelData(tt.fsys, "models")
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, want ok %v", err, tt.ok)
+			}
+			var merr *ModelError
+			if errors.As(err, &merr) {
+				if !reflect.DeepEqual(merr.Missing, tt.missing) || !reflect.DeepEqual(merr.Corrupt, tt.corrupt) {
+					t.Errorf("missing %v, corrupt %v; want %v, %v", merr.Missing, merr.Corrupt, tt.missing, tt.corrupt)
+				}
+				return
+			}
+			if tt.missing != nil || tt.corrupt != nil {
+				t.Fatalf("got error %v, want a *ModelError", err)
+			}
+			if !tt.ok {
+				return
+			}
+			if string(models.ShapePredictor) != "5 landmarks" || string(models.ResNet) != "resnet" || (models.CNN != nil) != tt.cnn {
+				t.Errorf("read %q, %q and %q", models.ShapePredictor, models.ResNet, models.CNN)
+			}
+			if models.ShapePredictorName != ShapePredictorModel {
+				t.Errorf("shape predictor %q, want %q", models.ShapePredictorName, ShapePredictorModel)
+			}
+		})
+	}
+}
+
+func TestModelDataCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		models  ModelData
+		missing []string
+		corrupt []string
+	}{
+		{"complete", ModelData{ShapePredictor: []byte("sp"), ResNet: []byte("resnet"), Manifest: Manifest{}}, nil, nil},
+		{"no ResNet", ModelData{ShapePredictor: []byte("sp"), Manifest: Manifest{}}, []string{ResNetModel}, nil},
+		{"named shape predictor", ModelData{ShapePredictorName: ShapePredictor68Model, ResNet: []byte("resnet"), Manifest: Manifest{}}, []string{ShapePredictor68Model}, nil},
+		// The default manifest pins the sizes of the dlib models.
+		{"default manifest", ModelData{ShapePredictor: []byte("sp"), ResNet: []byte("resnet")}, nil, []string{ShapePredictorModel, ResNetModel}},
+		{"CNN mismatch", ModelData{ShapePredictor: []byte("sp"), ResNet: []byte("resnet"), CNN: []byte("cnn"), Manifest: Manifest{CNNModel: {Size: 4}}}, nil, []string{CNNModel}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.models.check()
+			var merr *ModelError
+			if err != nil && !errors.As(err, &merr) {
+				t.Fatal(err)
+			}
+			if merr == nil {
+				merr = &ModelError{}
+			}
+			if !reflect.DeepEqual(merr.Missing, tt.missing) || !reflect.DeepEqual(merr.Corrupt, tt.corrupt) {
+				t.Errorf("missing %v, corrupt %v; want %v, %v", merr.Missing, merr.Corrupt, tt.missing, tt.corrupt)
+			}
+		})
+	}
+}
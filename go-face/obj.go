@@ -12,7 +12,9 @@ import "C"
 import (
 	"image"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"unsafe"
 )
 
@@ -45,6 +47,55 @@ func NewObjRecognizer(modelDir string) (rec *ObjRecognizer, err error) {
 	return
 }
 
+// NewObjRecognizerWithManifest Same as NewObjRecognizer but verifies the
+// detector file against its manifest entry, which is looked up by the base
+// name of the file and must be present.
+func NewObjRecognizerWithManifest(modelPath string, manifest Manifest) (rec *ObjRecognizer, err error) {
+	name := filepath.Base(modelPath)
+	if _, ok := manifest[name]; !ok {
+		err = &ModelError{Dir: filepath.Dir(modelPath), Missing: []string{name + " in manifest"}}
+		return
+	}
+	if err = manifest.VerifyFile(modelPath); err != nil {
+		if !os.IsNotExist(err) {
+			err = &ModelError{Dir: filepath.Dir(modelPath), Corrupt: []string{name}}
+		}
+		return
+	}
+	return NewObjRecognizer(modelPath)
+}
+
+// NewObjRecognizerFromData Same as NewObjRecognizer but deserializes the
+// detector from memory.
+func NewObjRecognizerFromData(model []byte) (rec *ObjRecognizer, err error) {
+	if len(model) == 0 {
+		err = SerializationError("Empty model")
+		return
+	}
+	cModel, cLen := cBytes(model)
+	ptr := C.objrec_init_mem(cModel, cLen)
+
+	if ptr.err_str != nil {
+		defer C.objrec_free(ptr)
+		defer C.free(unsafe.Pointer(ptr.err_str))
+		err = makeError(C.GoString(ptr.err_str), int(ptr.err_code))
+		return
+	}
+
+	rec = &ObjRecognizer{ptr}
+	return
+}
+
+// NewObjRecognizerFromFS Same as NewObjRecognizer but reads the detector
+// from the named file of fsys, e.g. an embed.FS.
+func NewObjRecognizerFromFS(fsys fs.FS, name string) (rec *ObjRecognizer, err error) {
+	model, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return
+	}
+	return NewObjRecognizerFromData(model)
+}
+
 func (rec *ObjRecognizer) recognizeFile(imgPath string) (rect []image.Rectangle, err error) {
 	fd, err := os.Open(imgPath)
 	if err != nil {
@@ -1,3 +1,4 @@
+#include <sstream>
 #include <dlib/dnn.h>
 #include <dlib/image_loader/image_loader.h>
 #include <dlib/graph_utils.h>
@@ -21,7 +22,21 @@ public:
 	ObjRec(const char *model)
 	{
 		object_detector<image_scanner_type> detector;
-		deserialize(model) >> detector;
+		try
+		{
+			deserialize(model) >> detector;
+		}
+		catch (serialization_error &e)
+		{
+			throw serialization_error(std::string(model) + ": " + e.what());
+		}
+		detector_ = detector;
+	}
+	ObjRec(const uint8_t *data, int len)
+	{
+		object_detector<image_scanner_type> detector;
+		std::istringstream in(std::string((const char *)data, len));
+		deserialize(detector, in);
 		detector_ = detector;
 	}
 	std::vector<rectangle> Recognize(const matrix<rgb_pixel> &img)
@@ -67,6 +82,27 @@ objrec *objrec_init(const char *model_dir)
 	return rec;
 }
 
+objrec *objrec_init_mem(const uint8_t *data, int len)
+{
+	objrec *rec = (objrec *)calloc(1, sizeof(objrec));
+	try
+	{
+		ObjRec *cls = new ObjRec(data, len);
+		rec->cls = (void *)cls;
+	}
+	catch (serialization_error &e)
+	{
+		rec->err_str = strdup(e.what());
+		rec->err_code = SERIALIZATION_ERROR;
+	}
+	catch (std::exception &e)
+	{
+		rec->err_str = strdup(e.what());
+		rec->err_code = UNKNOWN_ERROR;
+	}
+	return rec;
+}
+
 objret *objrec_recognize(objrec *rec, const uint8_t *img_data, int len)
 {
 	objret *ret = (objret *)calloc(1, sizeof(objret));
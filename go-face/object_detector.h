@@ -24,6 +24,7 @@ typedef struct objret {
 } objret;
 
 objrec* objrec_init(const char* model_dir);
+objrec* objrec_init_mem(const uint8_t* data, int len);
 objret* objrec_recognize(objrec* rec, const uint8_t* img_data, int len);
 void objrec_free(objrec* rec);
 
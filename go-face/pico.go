@@ -0,0 +1,219 @@
+package face
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"sort"
+)
+
+// PicoDetector is a pure Go face detector based on the pixel intensity
+// comparison cascades of Markuš et al. ("Object Detection with Pixel
+// Intensity Comparisons Organized in Decision Trees"). It is less accurate
+// than dlib but needs neither cgo nor model files other than the cascade,
+// e.g. the facefinder cascade shipped with pico. It doesn't compute
+// landmarks. Thread-safe once configured.
+type PicoDetector struct {
+	// MinSize and MaxSize bound the size of detected faces in pixels.
+	MinSize int
+	MaxSize int
+	// ShiftFactor is the step of the scanning window relative to its size.
+	ShiftFactor float64
+	// ScaleFactor is the growth of the scanning window between scales.
+	ScaleFactor float64
+	// IoUThreshold is the overlap above which detections are merged.
+	IoUThreshold float64
+	// MinConfidence drops detections with smaller cascade score.
+	MinConfidence float64
+
+	treeDepth int
+	treeNum   int
+	codes     []int8
+	preds     []float32
+	thresh    []float32
+}
+
+// picoDet is a detection in cascade coordinates: center and window size.
+type picoDet struct {
+	row, col, size int
+	q              float32
+}
+
+// NewPicoDetector unpacks the binary pico cascade.
+func NewPicoDetector(cascade []byte) (*PicoDetector, error) {
+	errCascade := errors.New("go-face: invalid pico cascade")
+	// Skip the first 8 bytes: version and unused size hints.
+	pos := 8
+	if len(cascade) < pos+8 {
+		return nil, errCascade
+	}
+	det := &PicoDetector{
+		MinSize:       20,
+		MaxSize:       1000,
+		ShiftFactor:   0.1,
+		ScaleFactor:   1.1,
+		IoUThreshold:  0.2,
+		MinConfidence: 5,
+	}
+	det.treeDepth = int(binary.LittleEndian.Uint32(cascade[pos:]))
+	det.treeNum = int(binary.LittleEndian.Uint32(cascade[pos+4:]))
+	pos += 8
+	if det.treeDepth <= 0 || det.treeDepth > 16 || det.treeNum <= 0 {
+		return nil, errCascade
+	}
+	leaves := 1 << det.treeDepth
+	codesLen := 4*leaves - 4
+	for t := 0; t < det.treeNum; t++ {
+		if len(cascade) < pos+codesLen+4*leaves+4 {
+			return nil, errCascade
+		}
+		// Node 0 is unused, keep the indexing of the original.
+		det.codes = append(det.codes, 0, 0, 0, 0)
+		for _, b := range cascade[pos : pos+codesLen] {
+			det.codes = append(det.codes, int8(b))
+		}
+		pos += codesLen
+		for i := 0; i < leaves; i++ {
+			det.preds = append(det.preds, math.Float32frombits(binary.LittleEndian.Uint32(cascade[pos:])))
+			pos += 4
+		}
+		det.thresh = append(det.thresh, math.Float32frombits(binary.LittleEndian.Uint32(cascade[pos:])))
+		pos += 4
+	}
+	return det, nil
+}
+
+// NewPicoDetectorFromFile Same as NewPicoDetector but reads the cascade
+// from file.
+func NewPicoDetectorFromFile(path string) (*PicoDetector, error) {
+	cascade, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPicoDetector(cascade)
+}
+
+// DetectImage implements Detector. The landmarks flag is ignored.
+func (det *PicoDetector) DetectImage(img image.Image, landmarks bool) ([]Detection, error) {
+	b := img.Bounds()
+	rows, cols := b.Dy(), b.Dx()
+	pixels := grayPixels(img)
+
+	var found []picoDet
+	maxSize := det.MaxSize
+	if m := min(rows, cols); maxSize > m {
+		maxSize = m
+	}
+	for size := det.MinSize; size <= maxSize; size = int(float64(size)*det.ScaleFactor) + 1 {
+		step := int(math.Max(det.ShiftFactor*float64(size), 1))
+		offset := size/2 + 1
+		for row := offset; row <= rows-offset; row += step {
+			for col := offset; col <= cols-offset; col += step {
+				if q := det.classify(row, col, size, pixels, cols); q > 0 {
+					found = append(found, picoDet{row, col, size, q})
+				}
+			}
+		}
+	}
+
+	var dets []Detection
+	for _, d := range det.cluster(found) {
+		if float64(d.q) < det.MinConfidence {
+			continue
+		}
+		half := d.size / 2
+		dets = append(dets, Detection{
+			Rectangle:  image.Rect(d.col-half, d.row-half, d.col+half, d.row+half).Add(b.Min),
+			Confidence: float64(d.q),
+		})
+	}
+	sort.Slice(dets, func(i, j int) bool {
+		return dets[i].Rectangle.Min.X < dets[j].Rectangle.Min.X
+	})
+	return dets, nil
+}
+
+// grayPixels returns the gray levels of the image row by row. Gray images
+// and the luma of JPEG images are copied as is, other images are converted
+// with a single draw.
+func grayPixels(img image.Image) []uint8 {
+	b := img.Bounds()
+	var gray *image.Gray
+	switch src := img.(type) {
+	case *image.Gray:
+		gray = src
+	case *image.YCbCr:
+		gray = &image.Gray{Pix: src.Y, Stride: src.YStride, Rect: src.Rect}
+	default:
+		gray = image.NewGray(b)
+		draw.Draw(gray, b, img, b.Min, draw.Src)
+	}
+	rows, cols := b.Dy(), b.Dx()
+	pixels := make([]uint8, rows*cols)
+	for y := 0; y < rows; y++ {
+		copy(pixels[y*cols:(y+1)*cols], gray.Pix[y*gray.Stride:])
+	}
+	return pixels
+}
+
+// classify runs the cascade on the window centered at row, col. Positive
+// score means a face.
+func (det *PicoDetector) classify(row, col, size int, pixels []uint8, dim int) float32 {
+	leaves := 1 << det.treeDepth
+	root := 0
+	var out float32
+	r, c := row*256, col*256
+	for i := 0; i < det.treeNum; i++ {
+		idx := 1
+		for j := 0; j < det.treeDepth; j++ {
+			code := det.codes[root+4*idx : root+4*idx+4]
+			p1 := ((r+int(code[0])*size)>>8)*dim + ((c + int(code[1])*size) >> 8)
+			p2 := ((r+int(code[2])*size)>>8)*dim + ((c + int(code[3])*size) >> 8)
+			idx *= 2
+			if pixels[p1] <= pixels[p2] {
+				idx++
+			}
+		}
+		out += det.preds[leaves*i+idx-leaves]
+		if out <= det.thresh[i] {
+			return -1
+		}
+		root += 4 * leaves
+	}
+	return out - det.thresh[det.treeNum-1]
+}
+
+// cluster merges overlapping detections, summing their scores.
+func (det *PicoDetector) cluster(found []picoDet) []picoDet {
+	assigned := make([]bool, len(found))
+	var clusters []picoDet
+	for i := range found {
+		if assigned[i] {
+			continue
+		}
+		var row, col, size, n int
+		var q float32
+		for j := i; j < len(found); j++ {
+			if picoIoU(found[i], found[j]) > det.IoUThreshold {
+				assigned[j] = true
+				row += found[j].row
+				col += found[j].col
+				size += found[j].size
+				q += found[j].q
+				n++
+			}
+		}
+		clusters = append(clusters, picoDet{row / n, col / n, size / n, q})
+	}
+	return clusters
+}
+
+func picoIoU(a, b picoDet) float64 {
+	overRow := math.Max(0, math.Min(float64(a.row+a.size/2), float64(b.row+b.size/2))-math.Max(float64(a.row-a.size/2), float64(b.row-b.size/2)))
+	overCol := math.Max(0, math.Min(float64(a.col+a.size/2), float64(b.col+b.size/2))-math.Max(float64(a.col-a.size/2), float64(b.col-b.size/2)))
+	inter := overRow * overCol
+	return inter / (float64(a.size*a.size+b.size*b.size) - inter)
+}
@@ -0,0 +1,137 @@
+package face
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// edgeCascade returns a cascade of a single tree of depth 1 which scores 1
+// the windows brighter a quarter to the left of their center than a quarter
+// to the right, and rejects the others.
+func edgeCascade() []byte {
+	cascade := make([]byte, 16)
+	binary.LittleEndian.PutUint32(cascade[8:], 1)
+	binary.LittleEndian.PutUint32(cascade[12:], 1)
+	cascade = append(cascade, 0, 0xc0, 0, 0x40)
+	for _, f := range []float32{1, -1, 0} {
+		cascade = binary.LittleEndian.AppendUint32(cascade, math.Float32bits(f))
+	}
+	return cascade
+}
+
+func TestNewPicoDetectorErrors(t *testing.T) {
+	depth := edgeCascade()
+	binary.LittleEndian.PutUint32(depth[8:], 17)
+	trees := edgeCascade()
+	binary.LittleEndian.PutUint32(trees[12:], 2)
+
+	tests := []struct {
+		name    string
+		cascade []byte
+		ok      bool
+	}{
+		{"valid", edgeCascade(), true},
+		{"empty", nil, false},
+		{"no header", edgeCascade()[:12], false},
+		{"too deep", depth, false},
+		{"truncated tree", edgeCascade()[:20], false},
+		{"missing tree", trees, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPicoDetector(tt.cascade)
+			if (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGrayPixels(t *testing.T) {
+	const w, h = 7, 5
+	level := func(x, y int) uint8 { return uint8(x*30 + y*7) }
+	gray := image.NewGray(image.Rect(0, 0, w+2, h+2))
+	nrgba := image.NewNRGBA(gray.Rect)
+	ycbcr := image.NewYCbCr(gray.Rect, image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 128, 128
+	}
+	for y := 0; y < h+2; y++ {
+		for x := 0; x < w+2; x++ {
+			gray.SetGray(x, y, color.Gray{Y: level(x, y)})
+			nrgba.SetNRGBA(x, y, color.NRGBA{R: level(x, y), G: level(x, y), B: level(x, y), A: 255})
+			ycbcr.Y[ycbcr.YOffset(x, y)] = level(x, y)
+		}
+	}
+	// Sub images keep the stride of their parent.
+	sub := image.Rect(1, 1, w+1, h+1)
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"gray", gray.SubImage(sub)},
+		{"ycbcr", ycbcr.SubImage(sub)},
+		{"nrgba", nrgba.SubImage(sub)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pixels := grayPixels(tt.img)
+			if len(pixels) != w*h {
+				t.Fatalf("got %d pixels, want %d", len(pixels), w*h)
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					want := color.GrayModel.Convert(tt.img.At(x+1, y+1)).(color.Gray).Y
+					if got := pixels[y*w+x]; got != want || got != level(x+1, y+1) {
+						t.Fatalf("pixel %d,%d is %d, want %d", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPicoDetectorDetectImage(t *testing.T) {
+	det, err := NewPicoDetector(edgeCascade())
+	if err != nil {
+		t.Fatal(err)
+	}
+	det.MinSize, det.MinConfidence = 20, 1
+	// A white left half on black, offset to check the rectangles are in
+	// image coordinates.
+	edge := image.NewGray(image.Rect(100, 50, 140, 90))
+	for y := 50; y < 90; y++ {
+		for x := 100; x < 120; x++ {
+			edge.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	tests := []struct {
+		name  string
+		img   image.Image
+		faces bool
+	}{
+		{"uniform", image.NewGray(edge.Rect), false},
+		{"edge", edge, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dets, err := det.DetectImage(tt.img, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(dets) > 0) != tt.faces {
+				t.Fatalf("got %d detections, want faces %v", len(dets), tt.faces)
+			}
+			for _, d := range dets {
+				if !d.Rectangle.Overlaps(edge.Rect) || d.Rectangle.Min.X > 120 || d.Rectangle.Max.X < 120 {
+					t.Errorf("detection %v doesn't cover the edge at x=120", d.Rectangle)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package face
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// Encoding selects how descriptors are stored.
+type Encoding int
+
+const (
+	// Float32 keeps descriptors as is, 512 bytes.
+	Float32 Encoding = iota
+	// Float16 stores half precision floats, 256 bytes.
+	Float16
+	// Int8 stores 8-bit integers with a per-descriptor scale, 132 bytes.
+	Int8
+)
+
+// Size returns the number of bytes of an encoded descriptor.
+func (e Encoding) Size() int {
+	switch e {
+	case Float16:
+		return 2 * len(Descriptor{})
+	case Int8:
+		return 4 + len(Descriptor{})
+	default:
+		return 4 * len(Descriptor{})
+	}
+}
+
+// Float16Descriptor is a descriptor of IEEE 754 half precision floats.
+type Float16Descriptor [128]uint16
+
+// Int8Descriptor is a descriptor quantized to Values times Scale.
+type Int8Descriptor struct {
+	Scale  float32
+	Values [128]int8
+}
+
+// QuantizeFloat16 rounds d to half precision.
+func QuantizeFloat16(d Descriptor) (q Float16Descriptor) {
+	for i, v := range d {
+		q[i] = toFloat16(v)
+	}
+	return
+}
+
+// Descriptor returns the float32 descriptor.
+func (q *Float16Descriptor) Descriptor() (d Descriptor) {
+	table := float16Table()
+	for i, v := range q {
+		d[i] = table[v]
+	}
+	return
+}
+
+// QuantizeInt8 scales d so that its largest component is 127.
+func QuantizeInt8(d Descriptor) (q Int8Descriptor) {
+	var max float32
+	for _, v := range d {
+		if a := float32(math.Abs(float64(v))); a > max {
+			max = a
+		}
+	}
+	if max == 0 {
+		return
+	}
+	q.Scale = max / 127
+	for i, v := range d {
+		q.Values[i] = int8(math.Round(float64(v / q.Scale)))
+	}
+	return
+}
+
+// Descriptor returns the float32 descriptor.
+func (q *Int8Descriptor) Descriptor() (d Descriptor) {
+	for i, v := range q.Values {
+		d[i] = float32(v) * q.Scale
+	}
+	return
+}
+
+// DistanceFloat16 Same as Distance but on half precision descriptors.
+func (m Metric) DistanceFloat16(q1, q2 *Float16Descriptor) float32 {
+	table := float16Table()
+	switch m {
+	case Cosine:
+		var dot, n1, n2 float32
+		for i := range q1 {
+			v1, v2 := table[q1[i]], table[q2[i]]
+			dot += v1 * v2
+			n1 += v1 * v1
+			n2 += v2 * v2
+		}
+		if n1 == 0 || n2 == 0 {
+			return 1
+		}
+		return float32(1 - float64(dot)/math.Sqrt(float64(n1)*float64(n2)))
+	default:
+		var sum float32
+		for i := range q1 {
+			diff := table[q1[i]] - table[q2[i]]
+			sum += diff * diff
+		}
+		return sum
+	}
+}
+
+// DistanceInt8 Same as Distance but on quantized descriptors, using integer
+// arithmetic.
+func (m Metric) DistanceInt8(q1, q2 *Int8Descriptor) float32 {
+	var dot, n1, n2 int32
+	for i := range q1.Values {
+		v1, v2 := int32(q1.Values[i]), int32(q2.Values[i])
+		dot += v1 * v2
+		n1 += v1 * v1
+		n2 += v2 * v2
+	}
+	switch m {
+	case Cosine:
+		// Scales cancel out.
+		if n1 == 0 || n2 == 0 {
+			return 1
+		}
+		return float32(1 - float64(dot)/math.Sqrt(float64(n1)*float64(n2)))
+	default:
+		s1, s2 := q1.Scale, q2.Scale
+		sum := s1*s1*float32(n1) + s2*s2*float32(n2) - 2*s1*s2*float32(dot)
+		if sum < 0 {
+			return 0
+		}
+		return sum
+	}
+}
+
+// Encode returns d in the encoding, prefixed with it so that
+// DecodeDescriptor doesn't need to know it.
+func (e Encoding) Encode(d Descriptor) []byte {
+	data := make([]byte, 1, 1+e.Size())
+	data[0] = byte(e)
+	switch e {
+	case Float16:
+		for _, v := range QuantizeFloat16(d) {
+			data = binary.LittleEndian.AppendUint16(data, v)
+		}
+	case Int8:
+		q := QuantizeInt8(d)
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(q.Scale))
+		for _, v := range q.Values {
+			data = append(data, byte(v))
+		}
+	default:
+		for _, v := range d {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+	}
+	return data
+}
+
+// DecodeDescriptor decodes a descriptor encoded by Encoding.Encode.
+func DecodeDescriptor(data []byte) (d Descriptor, err error) {
+	if len(data) == 0 || data[0] > byte(Int8) || len(data) != 1+Encoding(data[0]).Size() {
+		err = errors.New("go-face: invalid encoded descriptor")
+		return
+	}
+	e, data := Encoding(data[0]), data[1:]
+	switch e {
+	case Float16:
+		var q Float16Descriptor
+		for i := range q {
+			q[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		d = q.Descriptor()
+	case Int8:
+		var q Int8Descriptor
+		q.Scale = math.Float32frombits(binary.LittleEndian.Uint32(data))
+		for i := range q.Values {
+			q.Values[i] = int8(data[4+i])
+		}
+		d = q.Descriptor()
+	default:
+		for i := range d {
+			d[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+	}
+	return
+}
+
+// QuantizationReport measures the accuracy lost by an encoding.
+type QuantizationReport struct {
+	Encoding Encoding
+	// Bytes is the size of an encoded descriptor.
+	Bytes int
+	// MeanAbsError and MaxAbsError are the errors of the distances between
+	// pairs of samples, in units of the metric.
+	MeanAbsError float64
+	MaxAbsError  float64
+	// Top1Agreement is the fraction of samples whose nearest other sample
+	// is the same with and without quantization.
+	Top1Agreement float64
+}
+
+// MeasureQuantization compares the distances between all pairs of samples
+// with and without the encoding. Its cost is quadratic, a few thousand
+// samples are enough.
+func MeasureQuantization(samples []Descriptor, e Encoding, m Metric) QuantizationReport {
+	report := QuantizationReport{Encoding: e, Bytes: e.Size()}
+	if len(samples) < 2 {
+		return report
+	}
+	f16 := make([]Float16Descriptor, len(samples))
+	i8 := make([]Int8Descriptor, len(samples))
+	for i, s := range samples {
+		f16[i], i8[i] = QuantizeFloat16(s), QuantizeInt8(s)
+	}
+	quantized := func(i, j int) float32 {
+		switch e {
+		case Float16:
+			return m.DistanceFloat16(&f16[i], &f16[j])
+		case Int8:
+			return m.DistanceInt8(&i8[i], &i8[j])
+		default:
+			return m.distance(&samples[i], &samples[j])
+		}
+	}
+
+	var sum float64
+	var pairs, agree int
+	for i := range samples {
+		nearest, nearestQ := -1, -1
+		var best, bestQ float32
+		for j := range samples {
+			if i == j {
+				continue
+			}
+			dist, distQ := m.distance(&samples[i], &samples[j]), quantized(i, j)
+			if nearest < 0 || dist < best {
+				nearest, best = j, dist
+			}
+			if nearestQ < 0 || distQ < bestQ {
+				nearestQ, bestQ = j, distQ
+			}
+			if j > i {
+				err := math.Abs(float64(dist - distQ))
+				sum += err
+				report.MaxAbsError = math.Max(report.MaxAbsError, err)
+				pairs++
+			}
+		}
+		if nearest == nearestQ {
+			agree++
+		}
+	}
+	report.MeanAbsError = sum / float64(pairs)
+	report.Top1Agreement = float64(agree) / float64(len(samples))
+	return report
+}
+
+// toFloat16 converts v to half precision, rounding to nearest even.
+func toFloat16(v float32) uint16 {
+	bits := math.Float32bits(v)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+	switch {
+	case bits&0x7fffffff > 0x7f800000:
+		return sign | 0x7e00 // NaN
+	case exp >= 0x1f:
+		return sign | 0x7c00 // Overflow to infinity.
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		// Subnormal: shift in the implicit bit.
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		if rem > 1<<(shift-1) || (rem == 1<<(shift-1) && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		// May carry into the exponent, which is still correct.
+		half++
+	}
+	return sign | uint16(half)
+}
+
+var (
+	f16Once  sync.Once
+	f16Table []float32
+)
+
+// float16Table maps every half precision value to float32.
+func float16Table() []float32 {
+	f16Once.Do(func() {
+		f16Table = make([]float32, 1<<16)
+		for i := range f16Table {
+			h := uint32(i)
+			sign := h & 0x8000 << 16
+			exp := h >> 10 & 0x1f
+			mant := h & 0x3ff
+			var bits uint32
+			switch {
+			case exp == 0x1f:
+				bits = sign | 0x7f800000 | mant<<13
+			case exp != 0:
+				bits = sign | (exp+127-15)<<23 | mant<<13
+			case mant != 0:
+				// Subnormal: normalize.
+				e := uint32(127 - 15 + 1)
+				for mant&0x400 == 0 {
+					mant <<= 1
+					e--
+				}
+				bits = sign | e<<23 | (mant&0x3ff)<<13
+			default:
+				bits = sign
+			}
+			f16Table[i] = math.Float32frombits(bits)
+		}
+	})
+	return f16Table
+}
@@ -0,0 +1,87 @@
+package face
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	d := randomDescriptors(1, 3)[0]
+	var norm float64
+	for _, v := range d {
+		norm = math.Max(norm, math.Abs(float64(v)))
+	}
+	tests := []struct {
+		encoding Encoding
+		// maxErr is the largest error expected on a component.
+		maxErr float64
+	}{
+		{Float32, 0},
+		// 11 significant bits.
+		{Float16, norm / 2048},
+		// Half a quantization step.
+		{Int8, norm / 127 / 2 * 1.0001},
+	}
+	for _, tt := range tests {
+		data := tt.encoding.Encode(d)
+		if len(data) != 1+tt.encoding.Size() {
+			t.Errorf("encoding %v: %d bytes, want %d", tt.encoding, len(data), 1+tt.encoding.Size())
+		}
+		got, err := DecodeDescriptor(data)
+		if err != nil {
+			t.Fatalf("encoding %v: %v", tt.encoding, err)
+		}
+		for i := range d {
+			if diff := math.Abs(float64(got[i] - d[i])); diff > tt.maxErr {
+				t.Errorf("encoding %v: component %d is %v, want %v", tt.encoding, i, got[i], d[i])
+				break
+			}
+		}
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	tests := []struct {
+		v    float32
+		want uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		{1e6, 0x7c00},
+		// Smallest subnormal.
+		{5.9604645e-8, 0x0001},
+		{1e-9, 0x0000},
+		// Halfway between 1 and the next half, rounded to even.
+		{1 + 1.0/2048, 0x3c00},
+		{1 + 3.0/2048, 0x3c02},
+	}
+	for _, tt := range tests {
+		if got := toFloat16(tt.v); got != tt.want {
+			t.Errorf("toFloat16(%v) = %#04x, want %#04x", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeDescriptorLength(t *testing.T) {
+	valid := Int8.Encode(Descriptor{1})
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"valid", valid, true},
+		{"empty", nil, false},
+		{"encoding only", valid[:1], false},
+		{"truncated", valid[:len(valid)-1], false},
+		{"trailing byte", append(append([]byte(nil), valid...), 0), false},
+		{"unknown encoding", append([]byte{byte(Int8) + 1}, valid[1:]...), false},
+		{"float16 as float32", append([]byte{byte(Float32)}, Float16.Encode(Descriptor{})[1:]...), false},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeDescriptor(tt.data); (err == nil) != tt.ok {
+			t.Errorf("%s: DecodeDescriptor error %v", tt.name, err)
+		}
+	}
+}
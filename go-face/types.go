@@ -0,0 +1,44 @@
+package face
+
+import (
+	"image"
+	"math"
+)
+
+// Face holds coordinates and descriptor of the human face.
+type Face struct {
+	Rectangle  image.Rectangle
+	Descriptor Descriptor
+	Shapes     []image.Point
+	// Model is the ID of the embedding model which computed Descriptor,
+	// descriptors of different models can't be compared.
+	Model string
+}
+
+// Detection holds coordinates and detector confidence of the human face,
+// without descriptor. Shapes are only filled if landmarks were requested.
+type Detection struct {
+	Rectangle  image.Rectangle
+	Confidence float64
+	Shapes     []image.Point
+}
+
+// Descriptor holds 128-dimensional feature vector.
+type Descriptor [128]float32
+
+func SquaredEuclideanDistance(d1 Descriptor, d2 Descriptor) (sum float64) {
+	for i := range d1 {
+		sum = sum + math.Pow(float64(d2[i]-d1[i]), 2)
+	}
+
+	return sum
+}
+
+// New creates new face with the provided parameters.
+func New(r image.Rectangle, d Descriptor) Face {
+	return Face{Rectangle: r, Descriptor: d, Shapes: []image.Point{}}
+}
+
+func NewWithShape(r image.Rectangle, s []image.Point, d Descriptor) Face {
+	return Face{Rectangle: r, Descriptor: d, Shapes: s}
+}
@@ -0,0 +1,234 @@
+package recognizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+)
+
+// AnonymizeMethod selects how Anonymize hides faces.
+type AnonymizeMethod int
+
+const (
+	// Blur applies imag.GaussianBlur.
+	Blur AnonymizeMethod = iota
+	// StackBlur applies imag.StackBlur, faster on large faces.
+	StackBlur
+	// Pixelate replaces blocks of pixels by their average color.
+	Pixelate
+	// Mask covers faces with AnonymizeOptions.MaskImage, e.g. an emoji, or
+	// with AnonymizeOptions.Color.
+	Mask
+)
+
+// AnonymizeOptions configures Anonymize.
+type AnonymizeOptions struct {
+	Method AnonymizeMethod
+	// Strength is the sigma of Blur, the radius of StackBlur or the block
+	// size of Pixelate in pixels. If zero it is relative to every face, so
+	// that large faces aren't less hidden than small ones.
+	Strength float64
+	// Margin enlarges the face rectangles by a fraction of their size on
+	// every side, e.g. 0.2 to cover the hair and the chin.
+	Margin float64
+	// Ellipse hides the ellipse inscribed in the rectangle of every face
+	// instead of the whole rectangle.
+	Ellipse bool
+	// Feather is the fraction of the shape, from its border inwards, over
+	// which the effect fades out, e.g. 0.3. Zero gives a hard edge.
+	Feather float64
+	// Color of Mask when MaskImage is nil, black if nil.
+	Color color.Color
+	// MaskImage is scaled over every face by Mask, its transparent pixels
+	// keep the image.
+	MaskImage image.Image
+	// Skip holds the identities who consented to be shown, their faces are
+	// kept. Faces are then classified rather than only detected.
+	Skip []string
+}
+
+/*
+Anonymize hides the faces of the image file, see AnonymizeImage
+*/
+func (_this *Recognizer) Anonymize(Path string, Opt AnonymizeOptions) (image.Image, []image.Rectangle, error) {
+	img, err := _this.LoadImage(Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return _this.AnonymizeImage(img, Opt)
+}
+
+/*
+AnonymizeImage detects the faces of the image and hides them, except those
+positively classified as identities of Opt.Skip. Faces which can't be
+described or classified, e.g. computed by another embedding model, are
+hidden. It returns the anonymized copy of the image and the rectangles
+hidden.
+*/
+func (_this *Recognizer) AnonymizeImage(Img image.Image, Opt AnonymizeOptions) (image.Image, []image.Rectangle, error) {
+	dets, err := _this.detector.DetectImage(Img, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	rects := make([]image.Rectangle, len(dets))
+	for i, d := range dets {
+		rects[i] = d.Rectangle
+	}
+	if len(Opt.Skip) == 0 || len(rects) == 0 {
+		return AnonymizeFaces(Img, rects, Opt), rects, nil
+	}
+
+	skip := make(map[string]bool, len(Opt.Skip))
+	for _, id := range Opt.Skip {
+		skip[id] = true
+	}
+	img := Img
+	if _this.opt.UseGray {
+		img = _this.GrayScale(img)
+	}
+	faces, err := _this.embedder.RecognizeImageRects(img, rects)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Can't recognize: %w", err)
+	}
+	hidden := make([]image.Rectangle, 0, len(rects))
+	for _, r := range rects {
+		if !_this.consented(r, faces, skip) {
+			hidden = append(hidden, r)
+		}
+	}
+	return AnonymizeFaces(Img, hidden, Opt), hidden, nil
+}
+
+/*
+consented tells whether the face described for the detected rectangle is
+classified as one of the identities to skip
+*/
+func (_this *Recognizer) consented(Rect image.Rectangle, Faces []goFace.Face, Skip map[string]bool) bool {
+	// Faces are matched to the rectangles by overlap, embedders may drop
+	// or adjust some.
+	best, bestIoU := -1, 0.5
+	for i, f := range Faces {
+		if overlap := iou(Rect, f.Rectangle); overlap > bestIoU {
+			best, bestIoU = i, overlap
+		}
+	}
+	if best < 0 || modelOf(Faces[best].Model) != _this.opt.Model {
+		return false
+	}
+	person, _, ok := _this.classify(Faces[best].Descriptor)
+	return ok && Skip[person.Id]
+}
+
+/*
+AnonymizeFaces returns a copy of the image with the rectangles hidden, e.g.
+faces found by another detector. Bounds of the copy start at (0, 0).
+*/
+func AnonymizeFaces(Img image.Image, Rects []image.Rectangle, Opt AnonymizeOptions) *image.NRGBA {
+	dst := imag.Clone(Img)
+	offset := Img.Bounds().Min
+	for _, r := range Rects {
+		r = r.Sub(offset)
+		mx, my := int(float64(r.Dx())*Opt.Margin), int(float64(r.Dy())*Opt.Margin)
+		shape := image.Rect(r.Min.X-mx, r.Min.Y-my, r.Max.X+mx, r.Max.Y+my)
+		area := shape.Intersect(dst.Rect)
+		if area.Empty() {
+			continue
+		}
+		patch, origin := anonymizePatch(dst, shape, area, Opt)
+		blend(dst, patch, origin, shape, area, Opt)
+	}
+	return dst
+}
+
+/*
+anonymizePatch computes the hidden version of the area of the shape, returned
+with the position of its origin in the image
+*/
+func anonymizePatch(Img *image.NRGBA, Shape, Area image.Rectangle, Opt AnonymizeOptions) (*image.NRGBA, image.Point) {
+	size := float64(min(Shape.Dx(), Shape.Dy()))
+	switch Opt.Method {
+	case StackBlur:
+		radius := Opt.Strength
+		if radius <= 0 {
+			radius = size / 6
+		}
+		// StackBlur supports radiuses up to 254.
+		radius = math.Max(1, math.Min(radius, 254))
+		// Blur with the surroundings to avoid dark borders.
+		src := Area.Inset(-int(radius)).Intersect(Img.Rect)
+		if blurred, err := imag.StackBlur(imag.Crop(Img, src), uint32(radius)); err == nil {
+			return blurred, src.Min
+		}
+		// Falls back to Blur.
+	case Pixelate:
+		block := Opt.Strength
+		if block <= 0 {
+			block = size / 8
+		}
+		block = math.Max(block, 2)
+		// Blocks are aligned on the shape so that they don't change with
+		// the image borders.
+		w := int(math.Ceil(float64(Shape.Dx()) / block))
+		h := int(math.Ceil(float64(Shape.Dy()) / block))
+		padded := imag.Paste(imag.New(Shape.Dx(), Shape.Dy(), color.Black), imag.Crop(Img, Shape), Area.Min.Sub(Shape.Min))
+		small := imag.Resize(padded, w, h, imag.Box)
+		return imag.Resize(small, Shape.Dx(), Shape.Dy(), imag.NearestNeighbor), Shape.Min
+	case Mask:
+		if Opt.MaskImage != nil {
+			return imag.Resize(Opt.MaskImage, Shape.Dx(), Shape.Dy(), imag.Lanczos), Shape.Min
+		}
+		c := Opt.Color
+		if c == nil {
+			c = color.Black
+		}
+		return imag.New(Shape.Dx(), Shape.Dy(), c), Shape.Min
+	}
+	sigma := Opt.Strength
+	if sigma <= 0 {
+		sigma = size / 10
+	}
+	sigma = math.Max(sigma, 0.5)
+	src := Area.Inset(-int(math.Ceil(3 * sigma))).Intersect(Img.Rect)
+	return imag.GaussianBlur(imag.Crop(Img, src), sigma), src.Min
+}
+
+/*
+blend draws the patch over the area of the image, weighted by the feathered
+shape and the alpha of the patch
+*/
+func blend(Img, Patch *image.NRGBA, Origin image.Point, Shape, Area image.Rectangle, Opt AnonymizeOptions) {
+	cx := float64(Shape.Min.X+Shape.Max.X) / 2
+	cy := float64(Shape.Min.Y+Shape.Max.Y) / 2
+	rx, ry := float64(Shape.Dx())/2, float64(Shape.Dy())/2
+	for y := Area.Min.Y; y < Area.Max.Y; y++ {
+		for x := Area.Min.X; x < Area.Max.X; x++ {
+			// Normalized distance to the center, 1 on the border.
+			nx, ny := math.Abs((float64(x)+0.5-cx)/rx), math.Abs((float64(y)+0.5-cy)/ry)
+			r := math.Max(nx, ny)
+			if Opt.Ellipse {
+				r = math.Hypot(nx, ny)
+			}
+			weight := 0.0
+			switch {
+			case r > 1:
+			case Opt.Feather > 0:
+				weight = math.Min((1-r)/Opt.Feather, 1)
+			default:
+				weight = 1
+			}
+			if weight == 0 {
+				continue
+			}
+			p := Patch.PixOffset(x-Origin.X, y-Origin.Y)
+			i := Img.PixOffset(x, y)
+			weight *= float64(Patch.Pix[p+3]) / 255
+			for c := 0; c < 3; c++ {
+				Img.Pix[i+c] = uint8(math.Round(float64(Img.Pix[i+c])*(1-weight) + float64(Patch.Pix[p+c])*weight))
+			}
+		}
+	}
+}
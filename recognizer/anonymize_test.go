@@ -0,0 +1,94 @@
+package recognizer
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/oarkflow/imaging/imag"
+	"github.com/oarkflow/imaging/recognizer/fake"
+)
+
+// noiseImage returns an image whose every pixel is random, so that the fake
+// backend gives distant descriptors to all its faces.
+func noiseImage(w, h int, seed int64) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+func newFakeRecognizer(t *testing.T, opt *Option) (*Recognizer, *fake.Backend) {
+	t.Helper()
+	fb := fake.New()
+	if opt == nil {
+		opt = &Option{}
+	}
+	opt.Detector, opt.Embedder, opt.Classifier = fb, fb, fb
+	rec, err := New(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec, fb
+}
+
+func TestAnonymizeImageManyFaces(t *testing.T) {
+	const faces = 14
+	img := noiseImage(faces*50, 60, 1)
+	var rects []image.Rectangle
+	for i := 0; i < faces; i++ {
+		rects = append(rects, image.Rect(i*50+5, 10, i*50+45, 50))
+	}
+
+	rec, fb := newFakeRecognizer(t, nil)
+	// Enroll the first face from a crop, described as a whole image.
+	path := filepath.Join(t.TempDir(), "alice.png")
+	if err := imag.Save(imag.Crop(img, rects[0]), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.AddImageToDataset(path, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	rec.SetSamples()
+	fb.SetRects(rects...)
+
+	tests := []struct {
+		name    string
+		skip    []string
+		visible int
+	}{
+		{"no skip", nil, -1},
+		{"skip alice", []string{"alice"}, 0},
+		{"skip other", []string{"bob"}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := AnonymizeOptions{Method: Mask, Color: color.Black, Skip: tt.skip}
+			out, hidden, err := rec.AnonymizeImage(img, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := faces
+			if tt.visible >= 0 {
+				want--
+			}
+			if len(hidden) != want {
+				t.Fatalf("hidden %d faces, want %d", len(hidden), want)
+			}
+			for i, r := range rects {
+				center := image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+				masked := out.At(center.X, center.Y) == color.NRGBA{A: 255}
+				if masked == (i == tt.visible) {
+					t.Errorf("face %d masked %v", i, masked)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package recognizer
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+)
+
+// AvatarOptions configures Avatars.
+type AvatarOptions struct {
+	// Sizes of the square avatars in pixels, 256 if empty.
+	Sizes []int
+	// Margin enlarges the face by a fraction of its size on every side,
+	// e.g. 0.5 to include the hair and the neck.
+	Margin float64
+	// Fill pads the avatars exceeding the image, the border pixels are
+	// extended if nil.
+	Fill color.Color
+}
+
+// Avatar holds the square crops of a face.
+type Avatar struct {
+	Detection goFace.Detection
+	// Images holds the avatar of every size, in the order of
+	// AvatarOptions.Sizes.
+	Images []*image.NRGBA
+}
+
+/*
+Avatars returns square avatars of every face of the image file, see
+AvatarsImage
+*/
+func (_this *Recognizer) Avatars(Path string, Opt AvatarOptions) ([]Avatar, error) {
+	img, err := _this.LoadImage(Path)
+	if err != nil {
+		return nil, err
+	}
+	return _this.AvatarsImage(img, Opt)
+}
+
+/*
+AvatarsImage detects the faces of the image and returns square avatars of
+them, centered on the eyes when the detector provides landmarks
+*/
+func (_this *Recognizer) AvatarsImage(Img image.Image, Opt AvatarOptions) ([]Avatar, error) {
+	dets, err := _this.detector.DetectImage(Img, true)
+	if err != nil {
+		return nil, err
+	}
+	avatars := make([]Avatar, 0, len(dets))
+	for _, d := range dets {
+		avatars = append(avatars, Avatar{Detection: d, Images: AvatarCrop(Img, d.Rectangle, d.Shapes, Opt)})
+	}
+	return avatars, nil
+}
+
+/*
+AvatarCrop returns the square avatars of the face of the image in the sizes of
+the options. With landmarks, 5 as of goFace.ShapePredictorModel or 68, the
+eyes are centered horizontally and placed a bit above the center, so that
+tilted or cut faces look aligned.
+*/
+func AvatarCrop(Img image.Image, Rect image.Rectangle, Shapes []image.Point, Opt AvatarOptions) []*image.NRGBA {
+	sizes := Opt.Sizes
+	if len(sizes) == 0 {
+		sizes = []int{256}
+	}
+	side := float64(max(Rect.Dx(), Rect.Dy())) * (1 + 2*Opt.Margin)
+	cx := float64(Rect.Min.X+Rect.Max.X) / 2
+	cy := float64(Rect.Min.Y+Rect.Max.Y) / 2
+	top := cy - side/2
+	if left, right, ok := eyeCenters(Shapes); ok {
+		cx = (left[0] + right[0]) / 2
+		eyes := (left[1] + right[1]) / 2
+		// Eyes sit at 40% of the height of portraits, unless that cuts
+		// the face rectangle.
+		top = math.Max(float64(Rect.Max.Y)-side, math.Min(eyes-0.4*side, float64(Rect.Min.Y)))
+	}
+	x, y, n := int(math.Round(cx-side/2)), int(math.Round(top)), int(math.Round(side))
+	square := image.Rect(x, y, x+n, y+n)
+
+	crop := padCrop(Img, square, Opt.Fill)
+	avatars := make([]*image.NRGBA, len(sizes))
+	for i, size := range sizes {
+		avatars[i] = imag.Resize(crop, size, size, imag.Lanczos)
+	}
+	return avatars
+}
+
+/*
+eyeCenters returns the center of both eyes from 5 or 68 landmarks
+*/
+func eyeCenters(Shapes []image.Point) ([2]float64, [2]float64, bool) {
+	switch len(Shapes) {
+	case 68:
+		return center(Shapes[36:42]), center(Shapes[42:48]), true
+	case 5:
+		// Corners of both eyes, then the nose.
+		return center(Shapes[0:2]), center(Shapes[2:4]), true
+	}
+	return [2]float64{}, [2]float64{}, false
+}
+
+/*
+padCrop crops the rectangle of the image, padding the parts outside of the
+image with the color or, if nil, with the nearest border pixels
+*/
+func padCrop(Img image.Image, Rect image.Rectangle, Fill color.Color) *image.NRGBA {
+	bounds := Img.Bounds()
+	if Rect.In(bounds) {
+		return imag.Crop(Img, Rect)
+	}
+	if Fill != nil {
+		dst := imag.New(Rect.Dx(), Rect.Dy(), Fill)
+		return imag.Paste(dst, imag.Crop(Img, Rect), Rect.Intersect(bounds).Min.Sub(Rect.Min))
+	}
+	src := imag.Clone(Img)
+	dst := image.NewNRGBA(image.Rect(0, 0, Rect.Dx(), Rect.Dy()))
+	clamp := func(v, lo, hi int) int {
+		return max(lo, min(v, hi-1))
+	}
+	for y := 0; y < Rect.Dy(); y++ {
+		sy := clamp(Rect.Min.Y+y, bounds.Min.Y, bounds.Max.Y) - bounds.Min.Y
+		for x := 0; x < Rect.Dx(); x++ {
+			sx := clamp(Rect.Min.X+x, bounds.Min.X, bounds.Max.X) - bounds.Min.X
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):])
+		}
+	}
+	return dst
+}
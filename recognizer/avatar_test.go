@@ -0,0 +1,84 @@
+package recognizer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// positionImage returns an image whose pixels hold their coordinates in
+// their red and green channels.
+func positionImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+func TestAvatarCrop(t *testing.T) {
+	img := positionImage(200, 200)
+	red := color.NRGBA{R: 255, A: 255}
+	// Eyes at the height 70, centered at 75 with 5 landmarks, the corners
+	// of both eyes then the nose.
+	five := []image.Point{{60, 70}, {64, 70}, {86, 70}, {90, 70}, {75, 90}}
+
+	tests := []struct {
+		name   string
+		rect   image.Rectangle
+		shapes []image.Point
+		opt    AvatarOptions
+		// origin is the pixel of the image at the top left of the avatar,
+		// or the fill color if fill is set.
+		origin image.Point
+		fill   bool
+	}{
+		{"face", image.Rect(50, 50, 100, 100), nil, AvatarOptions{Sizes: []int{100}, Margin: 0.5}, image.Pt(25, 25), false},
+		{"5 landmarks", image.Rect(50, 50, 100, 100), five, AvatarOptions{Sizes: []int{100}, Margin: 0.5}, image.Pt(25, 30), false},
+		// Eyes at 100, centered at 130, 40% down the avatar.
+		{"68 landmarks", image.Rect(110, 90, 150, 130), landmarks(3, 130), AvatarOptions{Sizes: []int{80}, Margin: 0.5}, image.Pt(90, 68), false},
+		{"border extended", image.Rect(0, 0, 40, 40), nil, AvatarOptions{Sizes: []int{80}, Margin: 0.5}, image.Pt(0, 0), false},
+		{"border filled", image.Rect(0, 0, 40, 40), nil, AvatarOptions{Sizes: []int{80}, Margin: 0.5, Fill: red}, image.Pt(0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			avatars := AvatarCrop(img, tt.rect, tt.shapes, tt.opt)
+			if len(avatars) != 1 || avatars[0].Bounds().Size() != image.Pt(tt.opt.Sizes[0], tt.opt.Sizes[0]) {
+				t.Fatalf("got %d avatars, want one of %d pixels", len(avatars), tt.opt.Sizes[0])
+			}
+			got := avatars[0].NRGBAAt(0, 0)
+			want := color.NRGBA{R: uint8(tt.origin.X), G: uint8(tt.origin.Y), A: 255}
+			if tt.fill {
+				want = red
+			}
+			if got != want {
+				t.Errorf("avatar starts with %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAvatarsImage(t *testing.T) {
+	rec, fb := newFakeRecognizer(t, nil)
+	fb.SetRects(image.Rect(10, 10, 60, 60), image.Rect(100, 20, 160, 80))
+	avatars, err := rec.AvatarsImage(positionImage(200, 100), AvatarOptions{Sizes: []int{32, 64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avatars) != 2 {
+		t.Fatalf("got %d avatars, want 2", len(avatars))
+	}
+	for _, a := range avatars {
+		if len(a.Images) != 2 || a.Images[0].Bounds().Dx() != 32 || a.Images[1].Bounds().Dx() != 64 {
+			t.Errorf("avatars of %v don't have the sizes 32 and 64", a.Detection.Rectangle)
+		}
+	}
+
+	// The default size.
+	avatars, err = rec.AvatarsImage(positionImage(200, 100), AvatarOptions{})
+	if err != nil || len(avatars) == 0 || avatars[0].Images[0].Bounds() != image.Rect(0, 0, 256, 256) {
+		t.Errorf("got %v (%v), want avatars of 256 pixels", avatars, err)
+	}
+}
@@ -0,0 +1,51 @@
+package recognizer
+
+import (
+	"image"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+// An Embedder computes descriptors of the faces on an image.
+type Embedder interface {
+	// RecognizeImage returns all faces found on img sorted from left to
+	// right.
+	RecognizeImage(img image.Image) ([]goFace.Face, error)
+	// RecognizeImageRects returns the faces inside rects, skipping
+	// detection.
+	RecognizeImageRects(img image.Image, rects []image.Rectangle) ([]goFace.Face, error)
+}
+
+// A Classifier matches descriptors against known samples.
+type Classifier interface {
+	// SetSamples sets known descriptors and their categories.
+	SetSamples(samples []goFace.Descriptor, cats []int32)
+	// ClassifyThreshold returns the category of sample or a negative value
+	// if no known sample is within tolerance.
+	ClassifyThreshold(sample goFace.Descriptor, tolerance float32) int
+}
+
+// dlibBackend adapts goFace.Recognizer to Detector and Embedder, choosing
+// between HOG and CNN face detection.
+type dlibBackend struct {
+	rec    *goFace.Recognizer
+	useCNN bool
+}
+
+func (b dlibBackend) DetectImage(img image.Image, landmarks bool) ([]goFace.Detection, error) {
+	if b.useCNN {
+		return b.rec.DetectImageCNN(img, landmarks)
+	}
+	return b.rec.DetectImage(img, landmarks)
+}
+
+func (b dlibBackend) RecognizeImage(img image.Image) ([]goFace.Face, error) {
+	if b.useCNN {
+		return b.rec.RecognizeImageCNN(img)
+	}
+	return b.rec.RecognizeImage(img)
+}
+
+func (b dlibBackend) RecognizeImageRects(img image.Image, rects []image.Rectangle) ([]goFace.Face, error) {
+	return b.rec.RecognizeImageRects(img, rects)
+}
@@ -0,0 +1,77 @@
+package recognizer
+
+import (
+	"errors"
+	"image"
+	"path/filepath"
+	"testing"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+	"github.com/oarkflow/imaging/recognizer/fake"
+)
+
+func TestNewBackends(t *testing.T) {
+	// An empty model directory: dlib can't load, with or without cgo.
+	models := t.TempDir()
+	_, dlibErr := goFace.NewRecognizer(models)
+	cgoDisabled := errors.Is(dlibErr, goFace.ErrCgoDisabled)
+	path := filepath.Join(t.TempDir(), "face.png")
+	if err := imag.Save(noiseImage(64, 64, 1), path); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("all custom", func(t *testing.T) {
+		fb := fake.New()
+		rec, err := New(&Option{ModelDir: models, Detector: fb, Embedder: fb, Classifier: fb})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.rec != nil || rec.classifier != Classifier(fb) {
+			t.Fatal("dlib loaded with all the backends set")
+		}
+		if err := rec.AddImageToDataset(path, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		rec.SetSamples()
+		if faces, err := rec.ClassifyMultiples(path); err != nil || len(faces) != 1 || faces[0].Id != "alice" {
+			t.Errorf("got %v (%v), want alice", faces, err)
+		}
+	})
+
+	t.Run("custom detector", func(t *testing.T) {
+		fb := fake.New()
+		fb.SetRects(image.Rect(8, 8, 56, 56))
+		rec, err := New(&Option{ModelDir: models, Detector: fb, K: 3})
+		if !cgoDisabled {
+			// dlib is needed for the descriptors but has no models.
+			if err == nil {
+				t.Fatal("created without the dlib models")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("got %v, want the dlib parts to fail on use", err)
+		}
+		if _, ok := rec.classifier.(*goFace.Classifier); !ok {
+			t.Errorf("got classifier %T, want *goFace.Classifier", rec.classifier)
+		}
+		if dets, err := rec.DetectFaces(path, false); err != nil || len(dets) != 1 {
+			t.Errorf("detected %d faces (%v), want 1", len(dets), err)
+		}
+		if _, err := rec.RecognizeMultiples(path); !errors.Is(err, goFace.ErrCgoDisabled) {
+			t.Errorf("got %v, want goFace.ErrCgoDisabled", err)
+		}
+	})
+
+	t.Run("dlib", func(t *testing.T) {
+		_, err := New(&Option{ModelDir: models})
+		if err == nil {
+			t.Fatal("created without the dlib models")
+		}
+		var merr *goFace.ModelError
+		if cgoDisabled && !errors.Is(err, goFace.ErrCgoDisabled) || !cgoDisabled && !errors.As(err, &merr) {
+			t.Errorf("got %v", err)
+		}
+	})
+}
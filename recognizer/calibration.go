@@ -0,0 +1,175 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+// ROCPoint is the error rates when accepting distances up to Tolerance.
+type ROCPoint struct {
+	Tolerance float32
+	// FAR is the fraction of impostor pairs accepted.
+	FAR float64
+	// FRR is the fraction of genuine pairs rejected.
+	FRR float64
+}
+
+// Calibration reports the distances between faces of a labeled set and the
+// tolerance to use for a target false accept rate. Distances are in the
+// metric of the classifier, like Option.Tolerance.
+type Calibration struct {
+	// Genuine holds the sorted distances between faces of the same identity.
+	Genuine []float32
+	// Impostor holds the sorted distances between faces of different
+	// identities.
+	Impostor []float32
+	// ROC holds the error rates at evenly spaced tolerances.
+	ROC []ROCPoint
+	// EER is the equal error rate, where FAR and FRR meet, at EERTolerance.
+	EER          float64
+	EERTolerance float32
+	// Tolerance is the largest tolerance whose FAR doesn't exceed
+	// TargetFAR, with the rates it gives.
+	TargetFAR float64
+	Tolerance float32
+	FAR       float64
+	FRR       float64
+	// Skipped holds the images without exactly one face.
+	Skipped []string
+}
+
+/*
+Calibrate computes genuine and impostor distances between the faces of a
+labeled folder, holding one subfolder of images per identity, and recommends
+the tolerance for the target false accept rate, e.g. 0.001
+*/
+func (_this *Recognizer) Calibrate(Dir string, TargetFAR float64) (*Calibration, error) {
+	identities, err := os.ReadDir(Dir)
+	if err != nil {
+		return nil, err
+	}
+	var skipped []string
+	var descriptors [][]goFace.Descriptor
+	for _, identity := range identities {
+		if !identity.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(Dir, identity.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var ds []goFace.Descriptor
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			path := filepath.Join(Dir, identity.Name(), file.Name())
+			face, err := _this.RecognizeSingle(path)
+			if err != nil {
+				skipped = append(skipped, path)
+				continue
+			}
+			ds = append(ds, face.Descriptor)
+		}
+		descriptors = append(descriptors, ds)
+	}
+
+	var genuine, impostor []float32
+	for i, ds := range descriptors {
+		for a := range ds {
+			for b := a + 1; b < len(ds); b++ {
+				genuine = append(genuine, float32(_this.distance(ds[a], ds[b])))
+			}
+			for j := i + 1; j < len(descriptors); j++ {
+				for _, other := range descriptors[j] {
+					impostor = append(impostor, float32(_this.distance(ds[a], other)))
+				}
+			}
+		}
+	}
+	report, err := calibrate(genuine, impostor, TargetFAR)
+	if err != nil {
+		return nil, err
+	}
+	report.Skipped = skipped
+	return report, nil
+}
+
+/*
+calibrate computes the error rates of the genuine and impostor distances and
+the tolerances for the equal error rate and the target false accept rate
+*/
+func calibrate(Genuine, Impostor []float32, TargetFAR float64) (*Calibration, error) {
+	report := &Calibration{Genuine: Genuine, Impostor: Impostor, TargetFAR: TargetFAR}
+	if len(report.Genuine) == 0 || len(report.Impostor) == 0 {
+		return nil, errors.New("Can't calibrate: need two identities and an identity with two faces")
+	}
+	sort.Slice(report.Genuine, func(i, j int) bool { return report.Genuine[i] < report.Genuine[j] })
+	sort.Slice(report.Impostor, func(i, j int) bool { return report.Impostor[i] < report.Impostor[j] })
+
+	maxDist := report.Impostor[len(report.Impostor)-1]
+	if last := report.Genuine[len(report.Genuine)-1]; last > maxDist {
+		maxDist = last
+	}
+	const steps = 100
+	for i := 0; i <= steps; i++ {
+		report.ROC = append(report.ROC, report.rates(maxDist*float32(i)/steps))
+	}
+
+	// The equal error rate is searched at every observed distance, where
+	// the rates change.
+	bestGap := math.Inf(1)
+	for _, dists := range [][]float32{report.Genuine, report.Impostor} {
+		for _, t := range dists {
+			p := report.rates(t)
+			if gap := math.Abs(p.FAR - p.FRR); gap < bestGap {
+				bestGap = gap
+				report.EER, report.EERTolerance = (p.FAR+p.FRR)/2, t
+			}
+		}
+	}
+
+	// Accepting at most allowed impostor pairs means staying strictly below
+	// the next impostor distance, and so below all the pairs tied with it.
+	allowed := int(TargetFAR * float64(len(report.Impostor)))
+	if allowed >= len(report.Impostor) {
+		report.Tolerance = maxDist
+	} else {
+		next := report.Impostor[allowed]
+		if next <= 0 {
+			return nil, fmt.Errorf("Can't calibrate: impostor pairs at distance 0 exceed the target FAR %v", TargetFAR)
+		}
+		report.Tolerance = math.Nextafter32(next, float32(math.Inf(-1)))
+	}
+	p := report.rates(report.Tolerance)
+	report.FAR, report.FRR = p.FAR, p.FRR
+	return report, nil
+}
+
+/*
+rates returns the error rates when accepting distances up to Tolerance
+*/
+func (_this *Calibration) rates(Tolerance float32) ROCPoint {
+	accepted := func(dists []float32) int {
+		return sort.Search(len(dists), func(i int) bool { return dists[i] > Tolerance })
+	}
+	return ROCPoint{
+		Tolerance: Tolerance,
+		FAR:       float64(accepted(_this.Impostor)) / float64(len(_this.Impostor)),
+		FRR:       1 - float64(accepted(_this.Genuine))/float64(len(_this.Genuine)),
+	}
+}
+
+/*
+String returns a summary of the calibration
+*/
+func (_this *Calibration) String() string {
+	return fmt.Sprintf("%d genuine and %d impostor pairs, EER %.4f at tolerance %.4f, tolerance %.4f for FAR %.4f (FAR %.4f, FRR %.4f)",
+		len(_this.Genuine), len(_this.Impostor), _this.EER, _this.EERTolerance, _this.Tolerance, _this.TargetFAR, _this.FAR, _this.FRR)
+}
@@ -0,0 +1,72 @@
+package recognizer
+
+import "testing"
+
+func TestCalibrateTargetFARTies(t *testing.T) {
+	genuine := []float32{0.1, 0.3}
+	tests := []struct {
+		name      string
+		impostor  []float32
+		targetFAR float64
+		tolerance float32
+		far       float64
+	}{
+		{"no ties", []float32{0.2, 0.4, 0.6, 0.8}, 0.25, 0.4, 0.25},
+		{"ties", []float32{0.2, 0.5, 0.5, 0.5, 0.9}, 0.4, 0.5, 0.2},
+		{"all allowed", []float32{0.2, 0.4}, 1, 0.4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := calibrate(genuine, tt.impostor, tt.targetFAR)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.FAR != tt.far || report.FAR > tt.targetFAR {
+				t.Errorf("FAR %v at tolerance %v, want %v", report.FAR, report.Tolerance, tt.far)
+			}
+			if d := report.Tolerance - tt.tolerance; d > 1e-6 || d < -1e-6 {
+				t.Errorf("tolerance %v, want %v", report.Tolerance, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestCalibrateEER(t *testing.T) {
+	tests := []struct {
+		name      string
+		genuine   []float32
+		impostor  []float32
+		eer       float64
+		tolerance float32
+	}{
+		{"separable", []float32{0.1, 0.2}, []float32{0.5, 0.6}, 0, 0.2},
+		{"overlapping", []float32{0.1, 0.2, 0.3, 0.6}, []float32{0.4, 0.5, 0.7, 0.8}, 0.25, 0.4},
+		{"unbalanced", []float32{0.1, 0.5}, []float32{0.2, 0.6, 0.7, 0.8}, 0.125, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := calibrate(tt.genuine, tt.impostor, 0.01)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.EER != tt.eer || report.EERTolerance != tt.tolerance {
+				t.Errorf("EER %v at %v, want %v at %v", report.EER, report.EERTolerance, tt.eer, tt.tolerance)
+			}
+			if len(report.ROC) != 101 || report.ROC[0].FAR != 0 || report.ROC[100].FAR != 1 || report.ROC[100].FRR != 0 {
+				t.Errorf("unexpected ROC ends %+v, %+v", report.ROC[0], report.ROC[len(report.ROC)-1])
+			}
+		})
+	}
+}
+
+func TestCalibrateErrors(t *testing.T) {
+	if _, err := calibrate(nil, []float32{0.5}, 0.01); err == nil {
+		t.Error("calibrated without genuine pairs")
+	}
+	if _, err := calibrate([]float32{0.1}, nil, 0.01); err == nil {
+		t.Error("calibrated without impostor pairs")
+	}
+	if _, err := calibrate([]float32{0.1}, []float32{0, 0, 0.4}, 0.34); err == nil {
+		t.Error("calibrated with more impostor pairs at distance 0 than allowed")
+	}
+}
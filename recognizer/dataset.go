@@ -3,18 +3,70 @@ package recognizer
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+
+	goFace "github.com/oarkflow/imaging/go-face"
 )
 
+// storedData is Data as saved by SaveDataset, with the descriptor encoded
+// if Option.Encoding is set.
+type storedData struct {
+	Id         string
+	Descriptor *goFace.Descriptor `json:",omitempty"`
+	Encoded    []byte             `json:",omitempty"`
+	Model      string
+}
+
+// indexer is implemented by classifiers with a persistent index, such as
+// goFace.Classifier.
+type indexer interface {
+	Index() *goFace.Index
+	SetIndex(ix *goFace.Index)
+}
+
 /*
-SaveDataset saves dataset data to a json file
+indexPath returns the path of the index saved alongside the dataset
+*/
+func indexPath(Path string) string {
+	return Path + ".idx"
+}
+
+/*
+SaveDataset saves dataset data to a json file, with descriptors encoded
+with Option.Encoding, and the classifier index if any
 */
 func (_this *Recognizer) SaveDataset(Path string) error {
-	data, err := jsonMarshal(_this.dataset)
+	dataset := make([]storedData, len(_this.dataset))
+	for i := range _this.dataset {
+		f := &_this.dataset[i]
+		dataset[i] = storedData{Id: f.Id, Model: f.Model}
+		if _this.opt.Encoding == goFace.Float32 {
+			dataset[i].Descriptor = &f.Descriptor
+		} else {
+			dataset[i].Encoded = _this.opt.Encoding.Encode(f.Descriptor)
+		}
+	}
+	data, err := jsonMarshal(dataset)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(Path, data, 0777)
+	if err := os.WriteFile(Path, data, 0777); err != nil {
+		return err
+	}
+	idx, ok := _this.classifier.(indexer)
+	if !ok || idx.Index() == nil {
+		return nil
+	}
+	file, err := os.Create(indexPath(Path))
+	if err != nil {
+		return err
+	}
+	if err := idx.Index().Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
 /*
@@ -28,11 +80,44 @@ func (_this *Recognizer) LoadDataset(Path string) error {
 	if err != nil {
 		return err
 	}
-	Dataset := make([]Data, 0)
+	Dataset := make([]storedData, 0)
 	err = json.NewDecoder(file).Decode(&Dataset)
 	if err != nil {
 		return err
 	}
-	_this.dataset = append(_this.dataset, Dataset...)
+	for _, stored := range Dataset {
+		f := Data{Id: stored.Id, Model: stored.Model}
+		switch {
+		case stored.Encoded != nil:
+			if f.Descriptor, err = goFace.DecodeDescriptor(stored.Encoded); err != nil {
+				return fmt.Errorf("Can't decode descriptor of %s: %w", f.Id, err)
+			}
+		case stored.Descriptor != nil:
+			f.Descriptor = *stored.Descriptor
+		}
+		_this.appendSample(f)
+	}
+	return _this.loadIndex(Path)
+}
+
+/*
+loadIndex loads the index saved alongside the dataset so that SetSamples
+doesn't rebuild it
+*/
+func (_this *Recognizer) loadIndex(Path string) error {
+	idx, ok := _this.classifier.(indexer)
+	if !ok || !fileExists(indexPath(Path)) {
+		return nil
+	}
+	file, err := os.Open(indexPath(Path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ix, err := goFace.LoadIndex(file)
+	if err != nil {
+		return fmt.Errorf("Can't load index: %w", err)
+	}
+	idx.SetIndex(ix)
 	return nil
 }
@@ -0,0 +1,227 @@
+package recognizer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+)
+
+// ImageResult holds the faces classified in an image, for export.
+type ImageResult struct {
+	// Image is the path of the image.
+	Image  string
+	Width  int
+	Height int
+	Faces  []Face
+	// Err is set if the image couldn't be processed.
+	Err error
+}
+
+/*
+ClassifyFiles classifies the faces of every image file, as ClassifyMultiples,
+to export the results. Failures are reported in ImageResult.Err and don't stop
+the batch.
+*/
+func (_this *Recognizer) ClassifyFiles(Paths []string) []ImageResult {
+	results := make([]ImageResult, len(Paths))
+	for i, path := range Paths {
+		results[i].Image = path
+		img, err := _this.LoadImage(path)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Width, results[i].Height = img.Bounds().Dx(), img.Bounds().Dy()
+		results[i].Faces, results[i].Err = _this.ClassifyImage(img)
+	}
+	return results
+}
+
+// jsonFace is a face in a JSON line.
+type jsonFace struct {
+	Id        string   `json:"id"`
+	Box       [4]int   `json:"bbox"`
+	Distance  float32  `json:"distance"`
+	Landmarks [][2]int `json:"landmarks,omitempty"`
+}
+
+// jsonResult is a JSON line.
+type jsonResult struct {
+	Image  string     `json:"image"`
+	Width  int        `json:"width,omitempty"`
+	Height int        `json:"height,omitempty"`
+	Faces  []jsonFace `json:"faces"`
+	Error  string     `json:"error,omitempty"`
+}
+
+/*
+WriteJSONLines writes one JSON object per image: its path, size, error and
+faces with their id, bbox as [x, y, width, height], distance and landmarks
+*/
+func WriteJSONLines(W io.Writer, Results []ImageResult) error {
+	encoder := json.NewEncoder(W)
+	encoder.SetEscapeHTML(false)
+	for _, r := range Results {
+		line := jsonResult{Image: r.Image, Width: r.Width, Height: r.Height, Faces: make([]jsonFace, 0, len(r.Faces))}
+		if r.Err != nil {
+			line.Error = r.Err.Error()
+		}
+		for _, f := range r.Faces {
+			jf := jsonFace{Id: f.Id, Box: bbox(f.Rectangle), Distance: f.Distance}
+			for _, p := range f.Shapes {
+				jf.Landmarks = append(jf.Landmarks, [2]int{p.X, p.Y})
+			}
+			line.Faces = append(line.Faces, jf)
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+WriteCSV writes a header and one row per face with the image, its size, the
+face id, bbox and distance, and an error column. Images without faces get a
+row with empty face columns.
+*/
+func WriteCSV(W io.Writer, Results []ImageResult) error {
+	w := csv.NewWriter(W)
+	if err := w.Write([]string{"image", "width", "height", "id", "x", "y", "w", "h", "distance", "error"}); err != nil {
+		return err
+	}
+	for _, r := range Results {
+		errText := ""
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		width, height := strconv.Itoa(r.Width), strconv.Itoa(r.Height)
+		if len(r.Faces) == 0 {
+			if err := w.Write([]string{r.Image, width, height, "", "", "", "", "", "", errText}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, f := range r.Faces {
+			box := bbox(f.Rectangle)
+			row := []string{r.Image, width, height, f.Id}
+			for _, v := range box {
+				row = append(row, strconv.Itoa(v))
+			}
+			row = append(row, strconv.FormatFloat(float64(f.Distance), 'f', -1, 32), errText)
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// COCO is the subset of the COCO annotation format written by WriteCOCO and
+// read by ImportCOCO.
+type COCO struct {
+	Images      []COCOImage      `json:"images"`
+	Annotations []COCOAnnotation `json:"annotations"`
+	Categories  []COCOCategory   `json:"categories"`
+}
+
+// COCOImage is an image of a COCO file.
+type COCOImage struct {
+	Id       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// COCOAnnotation is a face of a COCO file, Box is x, y, width and height.
+type COCOAnnotation struct {
+	Id         int       `json:"id"`
+	ImageId    int       `json:"image_id"`
+	CategoryId int       `json:"category_id"`
+	Box        []float64 `json:"bbox"`
+	Area       float64   `json:"area"`
+	IsCrowd    int       `json:"iscrowd"`
+	// Keypoints are the landmarks as x, y and visibility triplets.
+	Keypoints    []float64 `json:"keypoints,omitempty"`
+	NumKeypoints int       `json:"num_keypoints,omitempty"`
+	// Distance is the distance of the classified face, not part of COCO.
+	Distance *float32 `json:"distance,omitempty"`
+}
+
+// COCOCategory is an identity of a COCO file.
+type COCOCategory struct {
+	Id            int      `json:"id"`
+	Name          string   `json:"name"`
+	Supercategory string   `json:"supercategory,omitempty"`
+	Keypoints     []string `json:"keypoints,omitempty"`
+}
+
+/*
+WriteCOCO writes the results as a COCO annotation file with one category per
+identity, in the order they appear, and the landmarks as keypoints named p0,
+p1... Images which couldn't be processed are left out.
+*/
+func WriteCOCO(W io.Writer, Results []ImageResult) error {
+	coco := COCO{Images: []COCOImage{}, Annotations: []COCOAnnotation{}, Categories: []COCOCategory{}}
+	categories := make(map[string]int)
+	keypoints := 0
+	for _, r := range Results {
+		for _, f := range r.Faces {
+			keypoints = max(keypoints, len(f.Shapes))
+		}
+	}
+	var names []string
+	for i := 0; i < keypoints; i++ {
+		names = append(names, fmt.Sprintf("p%d", i))
+	}
+
+	for _, r := range Results {
+		if r.Err != nil {
+			continue
+		}
+		img := COCOImage{Id: len(coco.Images) + 1, FileName: r.Image, Width: r.Width, Height: r.Height}
+		coco.Images = append(coco.Images, img)
+		for _, f := range r.Faces {
+			cat, ok := categories[f.Id]
+			if !ok {
+				cat = len(coco.Categories) + 1
+				categories[f.Id] = cat
+				coco.Categories = append(coco.Categories, COCOCategory{Id: cat, Name: f.Id, Supercategory: "face", Keypoints: names})
+			}
+			box := bbox(f.Rectangle)
+			distance := f.Distance
+			a := COCOAnnotation{
+				Id:         len(coco.Annotations) + 1,
+				ImageId:    img.Id,
+				CategoryId: cat,
+				Box:        []float64{float64(box[0]), float64(box[1]), float64(box[2]), float64(box[3])},
+				Area:       float64(box[2] * box[3]),
+				Distance:   &distance,
+			}
+			if keypoints > 0 {
+				a.Keypoints = make([]float64, 0, 3*keypoints)
+				for _, p := range f.Shapes {
+					a.Keypoints = append(a.Keypoints, float64(p.X), float64(p.Y), 2)
+				}
+				// Missing landmarks are not labeled.
+				for len(a.Keypoints) < 3*keypoints {
+					a.Keypoints = append(a.Keypoints, 0, 0, 0)
+				}
+				a.NumKeypoints = len(f.Shapes)
+			}
+			coco.Annotations = append(coco.Annotations, a)
+		}
+	}
+	encoder := json.NewEncoder(W)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(coco)
+}
+
+// bbox returns the rectangle as x, y, width and height.
+func bbox(R image.Rectangle) [4]int {
+	return [4]int{R.Min.X, R.Min.Y, R.Dx(), R.Dy()}
+}
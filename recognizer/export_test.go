@@ -0,0 +1,141 @@
+package recognizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/imaging/imag"
+)
+
+// classifiedFiles returns the results of an image with alice and bob
+// enrolled from it, and of a broken image file.
+func classifiedFiles(t *testing.T) (*Recognizer, []image.Rectangle, []ImageResult) {
+	t.Helper()
+	dir := t.TempDir()
+	img := noiseImage(300, 100, 1)
+	paths := []string{filepath.Join(dir, "a.png"), filepath.Join(dir, "broken.png")}
+	if err := imag.Save(img, paths[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths[1], []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rects := []image.Rectangle{image.Rect(10, 10, 90, 90), image.Rect(110, 10, 190, 90)}
+	rec, fb := newFakeRecognizer(t, nil)
+	fb.SetRects(rects...)
+	faces, err := fb.RecognizeImageRects(img, rects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.dataset = []Data{{Id: "alice", Descriptor: faces[0].Descriptor}, {Id: "bob", Descriptor: faces[1].Descriptor}}
+	rec.SetSamples()
+
+	results := rec.ClassifyFiles(paths)
+	if len(results) != 2 || results[0].Err != nil || len(results[0].Faces) != 2 || results[1].Err == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	return rec, rects, results
+}
+
+func TestWriteCOCOImport(t *testing.T) {
+	rec, rects, results := classifiedFiles(t)
+	path := filepath.Join(t.TempDir(), "faces.json")
+	var b bytes.Buffer
+	if err := WriteCOCO(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var coco COCO
+	if err := json.Unmarshal(b.Bytes(), &coco); err != nil {
+		t.Fatal(err)
+	}
+	// The broken image is left out.
+	if len(coco.Images) != 1 || coco.Images[0].Width != 300 || len(coco.Categories) != 2 || len(coco.Annotations) != 2 {
+		t.Fatalf("got %d images, %d categories and %d annotations, want 1, 2 and 2", len(coco.Images), len(coco.Categories), len(coco.Annotations))
+	}
+	if a := coco.Annotations[0]; a.NumKeypoints != 5 || len(a.Keypoints) != 15 || a.Area != 6400 {
+		t.Errorf("got %d keypoints of %v and area %v, want 5 of 15 values and 6400", a.NumKeypoints, a.Keypoints, a.Area)
+	}
+
+	imported, _ := newFakeRecognizer(t, nil)
+	imports, err := imported.ImportCOCO(path, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"alice", "bob"} {
+		if r := imports[i]; r.Status != Enrolled || r.Id != id || r.Rectangle != rects[i] {
+			t.Errorf("import %d is %q at %v %s (%v), want %q at %v", i, r.Id, r.Rectangle, r.Status, r.Err, id, rects[i])
+		}
+	}
+	if len(imported.dataset) != len(rec.dataset) {
+		t.Fatalf("imported %d samples, want %d", len(imported.dataset), len(rec.dataset))
+	}
+	for i, f := range imported.dataset {
+		if f.Id != rec.dataset[i].Id || f.Descriptor != rec.dataset[i].Descriptor {
+			t.Errorf("sample %d of %q differs from the classified one", i, f.Id)
+		}
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	_, rects, results := classifiedFiles(t)
+	var b bytes.Buffer
+	if err := WriteJSONLines(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	var lines []jsonResult
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		var line jsonResult
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if lines[0].Image != results[0].Image || lines[0].Width != 300 || lines[0].Error != "" || len(lines[0].Faces) != 2 {
+		t.Fatalf("unexpected first line %+v", lines[0])
+	}
+	for i, f := range lines[0].Faces {
+		if f.Box != bbox(rects[i]) || f.Id != results[0].Faces[i].Id || len(f.Landmarks) != 5 {
+			t.Errorf("face %d is %q at %v with %d landmarks", i, f.Id, f.Box, len(f.Landmarks))
+		}
+	}
+	if lines[1].Error == "" || lines[1].Faces == nil || len(lines[1].Faces) != 0 {
+		t.Errorf("got %+v, want an error and an empty list of faces", lines[1])
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	_, _, results := classifiedFiles(t)
+	var b bytes.Buffer
+	if err := WriteCSV(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, broken := results[0].Image, results[1].Image
+	want := [][]string{
+		{"image", "width", "height", "id", "x", "y", "w", "h", "distance", "error"},
+		{a, "300", "100", "alice", "10", "10", "80", "80", "0", ""},
+		{a, "300", "100", "bob", "110", "10", "80", "80", "0", ""},
+		{broken, "0", "0", "", "", "", "", "", "", results[1].Err.Error()},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %q, want %q", rows, want)
+	}
+}
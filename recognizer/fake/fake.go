@@ -0,0 +1,149 @@
+/*
+Package fake provides a deterministic in-memory face engine implementing the
+recognizer Detector, Embedder and Classifier interfaces, so that code built
+on the recognizer can be tested without dlib, models or cgo.
+*/
+package fake
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+// Backend finds the faces set with SetRects on every image, or a single face
+// covering the whole image if none were set. Descriptors are derived from
+// the pixels of the face: the same picture always gives the same
+// descriptor and different pictures give distant ones. Thread-safe.
+type Backend struct {
+	mu      sync.RWMutex
+	rects   []image.Rectangle
+	samples []goFace.Descriptor
+	cats    []int32
+}
+
+// New returns a Backend with no known samples.
+func New() *Backend {
+	return &Backend{}
+}
+
+// SetRects sets the face rectangles found on every image, relative to the
+// image origin. Rectangles outside of an image are ignored.
+func (b *Backend) SetRects(rects ...image.Rectangle) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rects = append([]image.Rectangle(nil), rects...)
+}
+
+func (b *Backend) locate(img image.Image) []image.Rectangle {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bounds := img.Bounds()
+	if len(b.rects) == 0 {
+		return []image.Rectangle{bounds}
+	}
+	var rects []image.Rectangle
+	for _, r := range b.rects {
+		r = r.Add(bounds.Min).Intersect(bounds)
+		if !r.Empty() {
+			rects = append(rects, r)
+		}
+	}
+	return rects
+}
+
+// DetectImage implements goFace.Detector. Landmarks are the four corners
+// and the center of the rectangle.
+func (b *Backend) DetectImage(img image.Image, landmarks bool) ([]goFace.Detection, error) {
+	var dets []goFace.Detection
+	for _, r := range b.locate(img) {
+		det := goFace.Detection{Rectangle: r, Confidence: 1}
+		if landmarks {
+			det.Shapes = shapes(r)
+		}
+		dets = append(dets, det)
+	}
+	return dets, nil
+}
+
+// RecognizeImage implements recognizer.Embedder.
+func (b *Backend) RecognizeImage(img image.Image) ([]goFace.Face, error) {
+	return b.RecognizeImageRects(img, b.locate(img))
+}
+
+// RecognizeImageRects implements recognizer.Embedder.
+func (b *Backend) RecognizeImageRects(img image.Image, rects []image.Rectangle) ([]goFace.Face, error) {
+	var faces []goFace.Face
+	for _, r := range rects {
+		faces = append(faces, goFace.NewWithShape(r, shapes(r), describe(img, r)))
+	}
+	return faces, nil
+}
+
+// SetSamples implements recognizer.Classifier.
+func (b *Backend) SetSamples(samples []goFace.Descriptor, cats []int32) {
+	if len(samples) != len(cats) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.samples = append([]goFace.Descriptor(nil), samples...)
+	b.cats = append([]int32(nil), cats...)
+}
+
+// ClassifyThreshold implements recognizer.Classifier: it returns the
+// category of the nearest sample within tolerance, negative tolerance
+// meaning no limit.
+func (b *Backend) ClassifyThreshold(sample goFace.Descriptor, tolerance float32) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	best, bestDist := -1, math.Inf(1)
+	for i, s := range b.samples {
+		dist := goFace.SquaredEuclideanDistance(s, sample)
+		if (tolerance < 0 || dist <= float64(tolerance)) && dist < bestDist {
+			best, bestDist = int(b.cats[i]), dist
+		}
+	}
+	return best
+}
+
+func shapes(r image.Rectangle) []image.Point {
+	return []image.Point{
+		r.Min, {X: r.Max.X, Y: r.Min.Y}, r.Max, {X: r.Min.X, Y: r.Max.Y},
+		{X: (r.Min.X + r.Max.X) / 2, Y: (r.Min.Y + r.Max.Y) / 2},
+	}
+}
+
+// describe samples the face on a 16x8 grid of gray levels and normalizes
+// it to zero mean and unit length, like the dlib descriptors.
+func describe(img image.Image, r image.Rectangle) goFace.Descriptor {
+	const cols, rows = 16, 8
+	var d goFace.Descriptor
+	var mean float64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			px := r.Min.X + (2*x+1)*r.Dx()/(2*cols)
+			py := r.Min.Y + (2*y+1)*r.Dy()/(2*rows)
+			v := float64(color.GrayModel.Convert(img.At(px, py)).(color.Gray).Y)
+			d[y*cols+x] = float32(v)
+			mean += v
+		}
+	}
+	mean /= float64(len(d))
+	var norm float64
+	for i := range d {
+		d[i] -= float32(mean)
+		norm += float64(d[i]) * float64(d[i])
+	}
+	if norm == 0 {
+		return d
+	}
+	norm = math.Sqrt(norm)
+	for i := range d {
+		d[i] = float32(float64(d[i]) / norm)
+	}
+	return d
+}
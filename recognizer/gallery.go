@@ -0,0 +1,161 @@
+package recognizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/oarkflow/imaging/freetype/truetype"
+	"github.com/oarkflow/imaging/gg"
+	"github.com/oarkflow/imaging/imag"
+)
+
+// GalleryItem is a face shown in a gallery.
+type GalleryItem struct {
+	// Img is the image of the face, loaded from Path if nil.
+	Img  image.Image
+	Path string
+	Face Face
+	// Caption is shown under the face, Face.Id if empty.
+	Caption string
+}
+
+// GalleryOptions configures Gallery. Zero values are replaced by defaults.
+type GalleryOptions struct {
+	// TileSize is the size of the square face crops, 128 by default.
+	TileSize int
+	// Padding is the space around the tiles, 8 by default.
+	Padding int
+	// Columns of the grid, 6 by default.
+	Columns int
+	// Rows of every page, all the items are on one page if zero.
+	Rows int
+	// Margin enlarges the face rectangles by a fraction of their size on
+	// every side before cropping, e.g. 0.2.
+	Margin float64
+	// Distance shows the distance of the faces next to their caption.
+	Distance   bool
+	Background color.Color
+	TextColor  color.Color
+	// Font of the captions, Go Regular if nil, of FontSize points, 12 by
+	// default.
+	Font     *truetype.Font
+	FontSize float64
+}
+
+/*
+Gallery renders a grid of the faces with their captions, e.g. to review the
+samples of an identity or the faces of a cluster, one image per page
+*/
+func (_this *Recognizer) Gallery(Items []GalleryItem, Opt GalleryOptions) ([]image.Image, error) {
+	if Opt.TileSize <= 0 {
+		Opt.TileSize = 128
+	}
+	if Opt.Padding <= 0 {
+		Opt.Padding = 8
+	}
+	if Opt.Columns <= 0 {
+		Opt.Columns = 6
+	}
+	if Opt.Background == nil {
+		Opt.Background = color.White
+	}
+	if Opt.TextColor == nil {
+		Opt.TextColor = color.Black
+	}
+	if Opt.FontSize <= 0 {
+		Opt.FontSize = 12
+	}
+	if Opt.Font == nil {
+		font, err := defaultFont()
+		if err != nil {
+			return nil, err
+		}
+		Opt.Font = font
+	}
+	perPage := len(Items)
+	if Opt.Rows > 0 {
+		perPage = Opt.Columns * Opt.Rows
+	}
+	if perPage == 0 {
+		return nil, nil
+	}
+
+	var pages []image.Image
+	for start := 0; start < len(Items); start += perPage {
+		end := min(start+perPage, len(Items))
+		page, err := _this.galleryPage(Items[start:end], Opt)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+/*
+galleryPage renders the items of a page
+*/
+func (_this *Recognizer) galleryPage(Items []GalleryItem, Opt GalleryOptions) (image.Image, error) {
+	captionHeight := int(math.Ceil(Opt.FontSize * 1.6))
+	cellW, cellH := Opt.TileSize+Opt.Padding, Opt.TileSize+captionHeight+Opt.Padding
+	columns := min(Opt.Columns, len(Items))
+	rows := (len(Items) + Opt.Columns - 1) / Opt.Columns
+
+	dc := gg.NewContext(columns*cellW+Opt.Padding, rows*cellH+Opt.Padding)
+	dc.SetColor(Opt.Background)
+	dc.Clear()
+	dc.SetFontFace(truetype.NewFace(Opt.Font, &truetype.Options{Size: Opt.FontSize}))
+	for i, item := range Items {
+		img := item.Img
+		if img == nil {
+			var err error
+			if img, err = _this.LoadImage(item.Path); err != nil {
+				return nil, fmt.Errorf("Can't load %s: %w", item.Path, err)
+			}
+		}
+		x := Opt.Padding + i%Opt.Columns*cellW
+		y := Opt.Padding + i/Opt.Columns*cellH
+
+		r := item.Face.Rectangle
+		mx, my := int(float64(r.Dx())*Opt.Margin), int(float64(r.Dy())*Opt.Margin)
+		r = image.Rect(r.Min.X-mx, r.Min.Y-my, r.Max.X+mx, r.Max.Y+my).Intersect(img.Bounds())
+		if !r.Empty() {
+			tile := imag.Fill(imag.Crop(img, r), Opt.TileSize, Opt.TileSize, imag.Center, imag.Lanczos)
+			dc.DrawImage(tile, x, y)
+		}
+
+		caption := item.Caption
+		if caption == "" {
+			caption = item.Face.Id
+		}
+		// The distance is kept whole, the caption is shortened if needed.
+		distance := ""
+		if Opt.Distance && item.Face.Distance >= 0 {
+			distance = fmt.Sprintf(" %.3f", item.Face.Distance)
+		}
+		w, _ := dc.MeasureString(distance)
+		caption = fitText(dc, caption, float64(Opt.TileSize)-w) + distance
+		dc.SetColor(Opt.TextColor)
+		dc.DrawStringAnchored(caption, float64(x+Opt.TileSize/2), float64(y+Opt.TileSize)+float64(captionHeight)/2, 0.5, 0.5)
+	}
+	return dc.Image(), nil
+}
+
+/*
+fitText shortens the text with an ellipsis until it fits the width
+*/
+func fitText(Dc *gg.Context, Text string, Width float64) string {
+	if w, _ := Dc.MeasureString(Text); w <= Width {
+		return Text
+	}
+	runes := []rune(Text)
+	for n := len(runes) - 1; n > 0; n-- {
+		s := string(runes[:n]) + "…"
+		if w, _ := Dc.MeasureString(s); w <= Width {
+			return s
+		}
+	}
+	return ""
+}
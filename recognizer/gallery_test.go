@@ -0,0 +1,120 @@
+package recognizer
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/imaging/freetype/truetype"
+	"github.com/oarkflow/imaging/gg"
+)
+
+func TestGallery(t *testing.T) {
+	rec, _ := newFakeRecognizer(t, nil)
+	red := image.NewUniform(color.RGBA{R: 255, A: 255})
+	item := func(id string) GalleryItem {
+		return GalleryItem{Img: red, Face: Face{Data: Data{Id: id}, Rectangle: image.Rect(0, 0, 50, 50)}}
+	}
+	items := make([]GalleryItem, 7)
+	for i := range items {
+		items[i] = item("alice")
+	}
+	// Tiles of 20 pixels with a caption of 16: cells of 24 by 40.
+	opt := GalleryOptions{TileSize: 20, Padding: 4, Columns: 3, FontSize: 10}
+
+	tests := []struct {
+		name  string
+		rows  int
+		items []GalleryItem
+		sizes []image.Point
+	}{
+		{"one page", 0, items, []image.Point{{76, 124}}},
+		{"pages", 2, items, []image.Point{{76, 84}, {28, 44}}},
+		{"single row", 0, items[:2], []image.Point{{52, 44}}},
+		{"no item", 2, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := opt
+			opt.Rows = tt.rows
+			pages, err := rec.Gallery(tt.items, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != len(tt.sizes) {
+				t.Fatalf("got %d pages, want %d", len(pages), len(tt.sizes))
+			}
+			for i, page := range pages {
+				if size := page.Bounds().Size(); size != tt.sizes[i] {
+					t.Errorf("page %d is %v, want %v", i, size, tt.sizes[i])
+				}
+				// The center of the first tile shows the face.
+				if r, g, _, _ := page.At(14, 14).RGBA(); r>>8 < 200 || g>>8 > 50 {
+					t.Errorf("page %d shows %v instead of the face", i, page.At(14, 14))
+				}
+			}
+		})
+	}
+
+	t.Run("face outside", func(t *testing.T) {
+		outside := item("alice")
+		outside.Img = image.NewRGBA(image.Rect(0, 0, 40, 40))
+		outside.Face.Rectangle = image.Rect(100, 100, 150, 150)
+		pages, err := rec.Gallery([]GalleryItem{outside}, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := color.GrayModel.Convert(pages[0].At(14, 14)).(color.Gray); c.Y != 255 {
+			t.Errorf("got %v, want the background", c)
+		}
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		missing := GalleryItem{Path: filepath.Join(t.TempDir(), "missing.png")}
+		if _, err := rec.Gallery([]GalleryItem{missing}, opt); err == nil {
+			t.Error("rendered a missing image")
+		}
+	})
+}
+
+func TestFitText(t *testing.T) {
+	font, err := defaultFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := gg.NewContext(10, 10)
+	dc.SetFontFace(truetype.NewFace(font, &truetype.Options{Size: 12}))
+	short, _ := dc.MeasureString("alice")
+
+	tests := []struct {
+		name string
+		text string
+		// width in multiples of the width of alice.
+		width     float64
+		shortened bool
+	}{
+		{"fits", "alice", 1, false},
+		{"shortened", "alice margaret", 1.5, true},
+		{"too narrow", "alice", 0.1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitText(dc, tt.text, tt.width*short)
+			if w, _ := dc.MeasureString(got); w > tt.width*short {
+				t.Errorf("got %q of %v, wider than %v", got, w, tt.width*short)
+			}
+			if !tt.shortened && got != tt.text {
+				t.Errorf("got %q, want %q", got, tt.text)
+			}
+			// Too narrow texts are dropped, not only their ellipsis kept.
+			if tt.width >= 1 && got == "" {
+				t.Errorf("dropped %q", tt.text)
+			}
+			if tt.shortened && got != "" && (!strings.HasSuffix(got, "…") || !strings.HasPrefix(tt.text, strings.TrimSuffix(got, "…"))) {
+				t.Errorf("got %q, want a prefix of %q with an ellipsis", got, tt.text)
+			}
+		})
+	}
+}
@@ -1,12 +1,12 @@
 package recognizer
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"fmt"
 	"image"
 	"image/color"
+	"math"
 	"os"
-	"path/filepath"
+	"sync"
 
 	"golang.org/x/image/font/gofont/goregular"
 
@@ -18,15 +18,11 @@ import (
 )
 
 /*
-LoadImage Load an image from file
+LoadImage Load an image from file. Files exceeding the configured limits
+are rejected with goFace.ImageTooLargeError before being decoded.
 */
 func (_this *Recognizer) LoadImage(Path string) (image.Image, error) {
-	existingImageFile, err := os.Open(Path)
-	if err != nil {
-		return nil, err
-	}
-	defer existingImageFile.Close()
-	return imag.Decode(existingImageFile, imag.AutoOrientation(true))
+	return _this.opt.Limits.Open(Path)
 }
 
 /*
@@ -48,62 +44,218 @@ func (_this *Recognizer) GrayScale(imgSrc image.Image) image.Image {
 	return imag.Grayscale(imgSrc)
 }
 
-/*
-createTempGrayFile create a temporary image in grayscale
-*/
-func (_this *Recognizer) createTempGrayFile(Path, Id string) (string, error) {
-	name := _this.tempFileName(Id, ".jpeg")
-	img, err := _this.LoadImage(Path)
-	if err != nil {
-		return "", err
-	}
-	img = _this.GrayScale(img)
-	err = _this.SaveImage(name, img)
-	if err != nil {
-		return "", err
-	}
-	return name, nil
+// DrawOptions configures DrawFacesWithOptions. Zero values draw like
+// DrawFaces: 4px blue rectangles labeled with the identity in 24pt Go
+// Regular.
+type DrawOptions struct {
+	// Colors holds the color of the faces of every identity.
+	Colors map[string]color.Color
+	// Color, if set, returns the color of every face instead, e.g. by
+	// confidence from 0 to 1. See ConfidenceColor.
+	Color func(F Face, Confidence float64) color.Color
+	// DefaultColor is used for identities without color, blue if nil.
+	DefaultColor color.Color
+	LineWidth    float64
+	// Font of the labels, Go Regular if nil, of FontSize points.
+	Font     *truetype.Font
+	FontSize float64
+	// LabelBackground draws the labels on a box of the face color, in black
+	// or white whichever contrasts most.
+	LabelBackground bool
+	// Confidence appends the confidence of the match to the labels.
+	Confidence bool
+	// Landmarks draws the landmarks of the faces as dots of LandmarkRadius,
+	// 2 if zero.
+	Landmarks      bool
+	LandmarkRadius float64
 }
 
-// tempFileName generates a temporary filename
-func (_this *Recognizer) tempFileName(prefix, suffix string) string {
-	randBytes := make([]byte, 16)
-	rand.Read(randBytes)
-	return filepath.Join(os.TempDir(), prefix+hex.EncodeToString(randBytes)+suffix)
+var (
+	goRegularOnce sync.Once
+	goRegular     *truetype.Font
+	goRegularErr  error
+)
+
+// defaultFont returns Go Regular, parsed once.
+func defaultFont() (*truetype.Font, error) {
+	goRegularOnce.Do(func() {
+		goRegular, goRegularErr = truetype.Parse(goregular.TTF)
+	})
+	return goRegular, goRegularErr
 }
 
 /*
 DrawFaces draws the faces identified in the original image
 */
 func (_this *Recognizer) DrawFaces(Path string, F []Face) (image.Image, error) {
+	return _this.DrawFacesWithOptions(Path, F, DrawOptions{})
+}
+
+/*
+DrawFacesWithOptions Same as DrawFaces but with configurable colors, labels
+and landmarks
+*/
+func (_this *Recognizer) DrawFacesWithOptions(Path string, F []Face, Opt DrawOptions) (image.Image, error) {
 	img, err := _this.LoadImage(Path)
 	if err != nil {
 		return nil, err
 	}
-	font, err := truetype.Parse(goregular.TTF)
-	if err != nil {
-		return nil, err
+	return _this.DrawFacesOnImage(img, F, Opt)
+}
+
+/*
+DrawFacesOnImage draws the faces on a copy of the image, e.g. a video frame.
+Labels are placed below, above or inside the faces, wherever they stay in the
+image without overlapping the labels already drawn.
+*/
+func (_this *Recognizer) DrawFacesOnImage(Img image.Image, F []Face, Opt DrawOptions) (image.Image, error) {
+	if Opt.DefaultColor == nil {
+		Opt.DefaultColor = color.RGBA{R: 0, G: 0, B: 255, A: 255}
+	}
+	if Opt.LineWidth <= 0 {
+		Opt.LineWidth = 4
+	}
+	if Opt.FontSize <= 0 {
+		Opt.FontSize = 24
+	}
+	if Opt.LandmarkRadius <= 0 {
+		Opt.LandmarkRadius = 2
 	}
-	face := truetype.NewFace(font, &truetype.Options{Size: 24})
-	dc := gg.NewContextForImage(img)
-	dc.SetFontFace(face)
+	if Opt.Font == nil {
+		font, err := defaultFont()
+		if err != nil {
+			return nil, err
+		}
+		Opt.Font = font
+	}
+	dc := gg.NewContextForImage(Img)
+	dc.SetFontFace(truetype.NewFace(Opt.Font, &truetype.Options{Size: Opt.FontSize}))
+	bounds := image.Rect(0, 0, dc.Width(), dc.Height())
+	offset := Img.Bounds().Min
+	var labels []image.Rectangle
 	for _, f := range F {
-		dc.SetRGB255(0, 0, 255)
+		confidence := _this.Confidence(f)
+		c := Opt.DefaultColor
+		if Opt.Color != nil {
+			c = Opt.Color(f, confidence)
+		} else if byId, ok := Opt.Colors[f.Id]; ok {
+			c = byId
+		}
+		r := f.Rectangle.Sub(offset)
 
-		x := float64(f.Rectangle.Min.X)
-		y := float64(f.Rectangle.Min.Y)
-		w := float64(f.Rectangle.Dx())
-		h := float64(f.Rectangle.Dy())
+		dc.DrawRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()))
+		dc.SetLineWidth(Opt.LineWidth)
+		dc.SetColor(c)
+		dc.Stroke()
 
-		dc.DrawString(f.Id, x, y+h+20)
+		if Opt.Landmarks {
+			for _, p := range f.Shapes {
+				p = p.Sub(offset)
+				dc.DrawCircle(float64(p.X), float64(p.Y), Opt.LandmarkRadius)
+			}
+			dc.Fill()
+		}
 
-		dc.DrawRectangle(x, y, w, h)
-		dc.SetLineWidth(4.0)
-		dc.SetStrokeStyle(gg.NewSolidPattern(color.RGBA{R: 0, G: 0, B: 255, A: 255}))
-		dc.Stroke()
+		text := f.Id
+		if Opt.Confidence && f.Id != Unknown && f.Id != "" {
+			text += fmt.Sprintf(" %.0f%%", 100*confidence)
+		}
+		if text == "" {
+			continue
+		}
+		w, h := dc.MeasureString(text)
+		pad := math.Ceil(Opt.FontSize / 6)
+		label := placeLabel(r, image.Pt(int(math.Ceil(w+2*pad)), int(math.Ceil(h+2*pad))), int(Opt.LineWidth), bounds, labels)
+		labels = append(labels, label)
+		if Opt.LabelBackground {
+			dc.DrawRectangle(float64(label.Min.X), float64(label.Min.Y), float64(label.Dx()), float64(label.Dy()))
+			dc.Fill()
+			dc.SetColor(contrastColor(c))
+		}
+		dc.DrawStringAnchored(text, float64(label.Min.X)+pad, float64(label.Min.Y)+pad, 0, 1)
+	}
+	return dc.Image(), nil
+}
+
+/*
+Confidence returns how confident the match of the face is, from 1 for a
+distance of 0 to 0 at the tolerance the identity was matched with, 0 for
+Unknown faces
+*/
+func (_this *Recognizer) Confidence(F Face) float64 {
+	if F.Id == Unknown || F.Distance < 0 {
+		return 0
+	}
+	tolerance := _this.tolerance(F.Id)
+	if tolerance <= 0 {
+		return 0
+	}
+	return math.Max(0, 1-float64(F.Distance/tolerance))
+}
+
+/*
+ConfidenceColor is a DrawOptions.Color going from red for no confidence to
+green for full confidence
+*/
+func ConfidenceColor(F Face, Confidence float64) color.Color {
+	Confidence = math.Max(0, math.Min(Confidence, 1))
+	return color.RGBA{R: uint8(255 * (1 - Confidence)), G: uint8(200 * Confidence), A: 255}
+}
+
+/*
+contrastColor returns black or white, whichever is the most readable on the
+color
+*/
+func contrastColor(C color.Color) color.Color {
+	r, g, b, _ := C.RGBA()
+	if 0.299*float64(r)+0.587*float64(g)+0.114*float64(b) > 0.55*0xffff {
+		return color.Black
+	}
+	return color.White
+}
+
+/*
+placeLabel returns where to draw a label of the size for the face, the first
+of below, above, inside bottom and inside top which is in the bounds and
+doesn't overlap the labels already placed
+*/
+func placeLabel(Face image.Rectangle, Size image.Point, LineWidth int, Bounds image.Rectangle, Labels []image.Rectangle) image.Rectangle {
+	half := (LineWidth + 1) / 2
+	candidates := []image.Point{
+		{Face.Min.X - half, Face.Max.Y + half},
+		{Face.Min.X - half, Face.Min.Y - half - Size.Y},
+		{Face.Min.X + half, Face.Max.Y - half - Size.Y},
+		{Face.Min.X + half, Face.Min.Y + half},
+	}
+	var fallback image.Rectangle
+	for _, p := range candidates {
+		label := image.Rectangle{Min: p, Max: p.Add(Size)}
+		// Shift horizontally into the image, e.g. faces cut by the border.
+		if label.Max.X > Bounds.Max.X {
+			label = label.Sub(image.Pt(label.Max.X-Bounds.Max.X, 0))
+		}
+		if label.Min.X < Bounds.Min.X {
+			label = label.Add(image.Pt(Bounds.Min.X-label.Min.X, 0))
+		}
+		if !label.In(Bounds) {
+			continue
+		}
+		if fallback.Empty() {
+			fallback = label
+		}
+		overlaps := false
+		for _, l := range Labels {
+			overlaps = overlaps || l.Overlaps(label)
+		}
+		if !overlaps {
+			return label
+		}
+	}
+	if fallback.Empty() {
+		// Larger than the image: keep it in view from the top left.
+		fallback = image.Rectangle{Min: Bounds.Min, Max: Bounds.Min.Add(Size)}
 	}
-	img = dc.Image()
-	return img, nil
+	return fallback
 }
 
 /*
@@ -0,0 +1,66 @@
+package recognizer
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestPlaceLabel(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	size := image.Pt(40, 10)
+	below := image.Rect(49, 101, 89, 111)
+
+	tests := []struct {
+		name   string
+		face   image.Rectangle
+		size   image.Point
+		labels []image.Rectangle
+		want   image.Rectangle
+	}{
+		{"below", image.Rect(50, 50, 100, 100), size, nil, below},
+		{"above at the bottom", image.Rect(50, 150, 100, 195), size, nil, image.Rect(49, 139, 89, 149)},
+		{"inside bottom", image.Rect(0, 0, 200, 200), size, nil, image.Rect(1, 189, 41, 199)},
+		{"shifted from the right", image.Rect(180, 50, 230, 100), size, nil, image.Rect(160, 101, 200, 111)},
+		{"shifted from the left", image.Rect(-10, 50, 40, 100), size, nil, image.Rect(0, 101, 40, 111)},
+		{"above an other label", image.Rect(50, 50, 100, 100), size, []image.Rectangle{below}, image.Rect(49, 39, 89, 49)},
+		{"overlapping everywhere", image.Rect(50, 50, 100, 100), size, []image.Rectangle{bounds}, below},
+		{"larger than the image", image.Rect(50, 50, 100, 100), image.Pt(300, 10), nil, image.Rect(0, 0, 300, 10)},
+	}
+	for _, tt := range tests {
+		if got := placeLabel(tt.face, tt.size, 2, bounds, tt.labels); got != tt.want {
+			t.Errorf("%s: placed at %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	rec, _ := newFakeRecognizer(t, &Option{Tolerance: 0.4, AdaptiveTolerance: true})
+	// Alice's samples are 0.25 apart, which is her tolerance, bob has a
+	// single sample and keeps Tolerance.
+	rec.dataset = []Data{
+		{Id: "alice", Descriptor: descriptor(1)},
+		{Id: "alice", Descriptor: descriptor(1, 0, 0.5)},
+		{Id: "bob", Descriptor: descriptor(0, 1)},
+	}
+	rec.SetSamples()
+
+	tests := []struct {
+		name string
+		face Face
+		want float64
+	}{
+		{"learned tolerance", Face{Data: Data{Id: "alice"}, Distance: 0.125}, 0.5},
+		{"default tolerance", Face{Data: Data{Id: "bob"}, Distance: 0.2}, 0.5},
+		{"beyond tolerance", Face{Data: Data{Id: "alice"}, Distance: 0.3}, 0},
+		{"exact match", Face{Data: Data{Id: "alice"}, Distance: 0}, 1},
+		{"unknown", Face{Data: Data{Id: Unknown}, Distance: -1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rec.Confidence(tt.face); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
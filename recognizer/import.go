@@ -0,0 +1,256 @@
+package recognizer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportStatus is the outcome of an imported annotation.
+type ImportStatus string
+
+const (
+	// Enrolled annotations were added to the dataset.
+	Enrolled ImportStatus = "enrolled"
+	// Redundant annotations were dropped by DedupEpsilon or MaxSamples.
+	Redundant ImportStatus = "redundant"
+	// NoDescriptor annotations are outside of the image or the embedder
+	// computed no descriptor for them.
+	NoDescriptor ImportStatus = "no descriptor"
+	// ImageFailed annotations are in an image that couldn't be loaded or
+	// embedded.
+	ImageFailed ImportStatus = "image failed"
+	// Skipped annotations have no name, are crowds, or are of a skipped
+	// category.
+	Skipped ImportStatus = "skipped"
+)
+
+// ImportOptions configures ImportCOCO and ImportVOC.
+type ImportOptions struct {
+	// ImageDir is the directory the image file names are relative to, the
+	// directory of the annotations if empty.
+	ImageDir string
+	// Skip holds names of categories or objects which are not identities,
+	// e.g. "person". Unknown is always skipped.
+	Skip []string
+}
+
+// ImportResult reports the import of an annotation.
+type ImportResult struct {
+	Image     string
+	Id        string
+	Rectangle image.Rectangle
+	Status    ImportStatus
+	// Err tells why the annotation wasn't enrolled, if not redundant.
+	Err error
+}
+
+// annotation is a face box of an imported image.
+type annotation struct {
+	id   string
+	rect image.Rectangle
+}
+
+/*
+ImportCOCO enrolls the faces annotated in a COCO file, one identity per
+category name. Descriptors are computed for the annotated boxes without
+running the detector. Call SetSamples afterwards.
+*/
+func (_this *Recognizer) ImportCOCO(Path string, Opt ImportOptions) ([]ImportResult, error) {
+	data, err := os.ReadFile(Path)
+	if err != nil {
+		return nil, err
+	}
+	var coco COCO
+	if err := json.Unmarshal(data, &coco); err != nil {
+		return nil, fmt.Errorf("Can't parse %s: %w", Path, err)
+	}
+	if Opt.ImageDir == "" {
+		Opt.ImageDir = filepath.Dir(Path)
+	}
+	names := make(map[int]string, len(coco.Categories))
+	for _, c := range coco.Categories {
+		names[c.Id] = c.Name
+	}
+	files := make(map[int]string, len(coco.Images))
+	for _, img := range coco.Images {
+		files[img.Id] = img.FileName
+	}
+
+	var order []string
+	images := make(map[string][]annotation)
+	var results []ImportResult
+	for _, a := range coco.Annotations {
+		file, ok := files[a.ImageId]
+		if !ok {
+			results = append(results, ImportResult{Id: names[a.CategoryId], Status: Skipped, Err: fmt.Errorf("Unknown image %d", a.ImageId)})
+			continue
+		}
+		var rect image.Rectangle
+		if len(a.Box) == 4 {
+			rect = image.Rect(round(a.Box[0]), round(a.Box[1]), round(a.Box[0]+a.Box[2]), round(a.Box[1]+a.Box[3]))
+		}
+		if a.IsCrowd != 0 {
+			results = append(results, ImportResult{Image: file, Id: names[a.CategoryId], Rectangle: rect, Status: Skipped, Err: fmt.Errorf("Crowd annotation")})
+			continue
+		}
+		if _, ok := images[file]; !ok {
+			order = append(order, file)
+		}
+		images[file] = append(images[file], annotation{id: names[a.CategoryId], rect: rect})
+	}
+	for _, file := range order {
+		results = append(results, _this.importImage(file, images[file], Opt)...)
+	}
+	return results, nil
+}
+
+// vocAnnotation is a Pascal VOC annotation file.
+type vocAnnotation struct {
+	Filename string `xml:"filename"`
+	Objects  []struct {
+		Name string `xml:"name"`
+		Box  struct {
+			XMin float64 `xml:"xmin"`
+			YMin float64 `xml:"ymin"`
+			XMax float64 `xml:"xmax"`
+			YMax float64 `xml:"ymax"`
+		} `xml:"bndbox"`
+	} `xml:"object"`
+}
+
+/*
+ImportVOC enrolls the faces annotated in the Pascal VOC XML files of the
+directory, one identity per object name. Descriptors are computed for the
+annotated boxes without running the detector. Call SetSamples afterwards.
+*/
+func (_this *Recognizer) ImportVOC(Dir string, Opt ImportOptions) ([]ImportResult, error) {
+	paths, err := filepath.Glob(filepath.Join(Dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	if Opt.ImageDir == "" {
+		Opt.ImageDir = Dir
+	}
+	var results []ImportResult
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return results, err
+		}
+		var voc vocAnnotation
+		if err := xml.Unmarshal(data, &voc); err != nil {
+			return results, fmt.Errorf("Can't parse %s: %w", path, err)
+		}
+		if voc.Filename == "" {
+			// Images are usually named like their annotations.
+			voc.Filename = strings.TrimSuffix(filepath.Base(path), ".xml") + ".jpg"
+		}
+		annotations := make([]annotation, len(voc.Objects))
+		for i, o := range voc.Objects {
+			annotations[i] = annotation{
+				id:   strings.TrimSpace(o.Name),
+				rect: image.Rect(round(o.Box.XMin), round(o.Box.YMin), round(o.Box.XMax), round(o.Box.YMax)),
+			}
+		}
+		results = append(results, _this.importImage(voc.Filename, annotations, Opt)...)
+	}
+	return results, nil
+}
+
+/*
+importImage computes the descriptors of the annotated faces of the image and
+enrolls them
+*/
+func (_this *Recognizer) importImage(File string, Annotations []annotation, Opt ImportOptions) []ImportResult {
+	results := make([]ImportResult, len(Annotations))
+	skip := make(map[string]bool, len(Opt.Skip)+1)
+	skip[Unknown] = true
+	for _, name := range Opt.Skip {
+		skip[name] = true
+	}
+	var pending []int
+	for i, a := range Annotations {
+		results[i] = ImportResult{Image: File, Id: a.id, Rectangle: a.rect}
+		switch {
+		case a.id == "" || skip[a.id]:
+			results[i].Status = Skipped
+		case a.rect.Empty():
+			results[i].Status = NoDescriptor
+			results[i].Err = fmt.Errorf("Empty box")
+		default:
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	path := File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(Opt.ImageDir, File)
+	}
+	fail := func(err error) []ImportResult {
+		for _, i := range pending {
+			results[i].Status = ImageFailed
+			results[i].Err = err
+		}
+		return results
+	}
+	img, err := _this.loadFaceImage(path)
+	if err != nil {
+		return fail(err)
+	}
+	var rects []image.Rectangle
+	inside := pending[:0]
+	for _, i := range pending {
+		if !Annotations[i].rect.In(img.Bounds()) {
+			results[i].Status = NoDescriptor
+			results[i].Err = fmt.Errorf("Box outside of the image")
+			continue
+		}
+		rects = append(rects, Annotations[i].rect)
+		inside = append(inside, i)
+	}
+	pending = inside
+	if len(pending) == 0 {
+		return results
+	}
+	faces, err := _this.embedder.RecognizeImageRects(img, rects)
+	if err != nil {
+		return fail(fmt.Errorf("Can't recognize: %w", err))
+	}
+
+	for j, i := range pending {
+		// Faces are matched to the boxes by overlap, embedders may drop
+		// or adjust some.
+		best, bestIoU := -1, 0.5
+		for k, f := range faces {
+			if overlap := iou(rects[j], f.Rectangle); overlap > bestIoU {
+				best, bestIoU = k, overlap
+			}
+		}
+		if best < 0 {
+			results[i].Status = NoDescriptor
+			results[i].Err = fmt.Errorf("No descriptor computed")
+			continue
+		}
+		f := Data{Id: results[i].Id, Descriptor: faces[best].Descriptor, Model: modelOf(faces[best].Model)}
+		results[i].Status = Redundant
+		if _this.addSample(f) {
+			results[i].Status = Enrolled
+		}
+	}
+	return results
+}
+
+func round(V float64) int {
+	return int(math.Round(V))
+}
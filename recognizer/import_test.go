@@ -0,0 +1,119 @@
+package recognizer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oarkflow/imaging/imag"
+)
+
+func TestImportCOCO(t *testing.T) {
+	dir := t.TempDir()
+	if err := imag.Save(noiseImage(300, 100, 1), filepath.Join(dir, "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	coco := `{
+		"images": [{"id": 1, "file_name": "a.png"}, {"id": 2, "file_name": "missing.png"}],
+		"categories": [{"id": 1, "name": "alice"}, {"id": 2, "name": "bob"}, {"id": 3, "name": "person"}],
+		"annotations": [
+			{"image_id": 1, "category_id": 1, "bbox": [10, 10, 80, 80]},
+			{"image_id": 1, "category_id": 2, "bbox": [110, 10, 80, 80]},
+			{"image_id": 1, "category_id": 1, "bbox": [10, 10, 80, 80]},
+			{"image_id": 1, "category_id": 3, "bbox": [200, 10, 80, 80]},
+			{"image_id": 1, "category_id": 1, "bbox": [10, 10, 80, 80], "iscrowd": 1},
+			{"image_id": 1, "category_id": 2, "bbox": [250, 10, 80, 80]},
+			{"image_id": 1, "category_id": 2, "bbox": [0, 0, 0, 0]},
+			{"image_id": 9, "category_id": 1, "bbox": [10, 10, 80, 80]},
+			{"image_id": 2, "category_id": 1, "bbox": [10, 10, 80, 80]}
+		]
+	}`
+	path := filepath.Join(dir, "faces.json")
+	if err := os.WriteFile(path, []byte(coco), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, _ := newFakeRecognizer(t, &Option{DedupEpsilon: 0.01})
+	results, err := rec.ImportCOCO(path, ImportOptions{Skip: []string{"person"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Crowds and annotations of unknown images come first, then the
+	// annotations of every image.
+	want := []struct {
+		id     string
+		status ImportStatus
+		err    bool
+	}{
+		{"alice", Skipped, true},
+		{"alice", Skipped, true},
+		{"alice", Enrolled, false},
+		{"bob", Enrolled, false},
+		{"alice", Redundant, false},
+		{"person", Skipped, false},
+		{"bob", NoDescriptor, true},
+		{"bob", NoDescriptor, true},
+		{"alice", ImageFailed, true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, r := range results {
+		if r.Id != want[i].id || r.Status != want[i].status {
+			t.Errorf("result %d is %q %s, want %q %s", i, r.Id, r.Status, want[i].id, want[i].status)
+		}
+		if (r.Err != nil) != want[i].err {
+			t.Errorf("result %d: %s with error %v", i, r.Status, r.Err)
+		}
+	}
+	if len(rec.dataset) != 2 {
+		t.Errorf("%d samples enrolled, want 2", len(rec.dataset))
+	}
+}
+
+func TestImportVOC(t *testing.T) {
+	dir := t.TempDir()
+	if err := imag.Save(noiseImage(100, 100, 2), filepath.Join(dir, "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.xml": `<annotation><filename>a.png</filename>
+			<object><name> alice </name><bndbox><xmin>10</xmin><ymin>10</ymin><xmax>90</xmax><ymax>90</ymax></bndbox></object>
+			<object><name></name><bndbox><xmin>10</xmin><ymin>10</ymin><xmax>90</xmax><ymax>90</ymax></bndbox></object>
+			<object><name>person</name><bndbox><xmin>10</xmin><ymin>10</ymin><xmax>90</xmax><ymax>90</ymax></bndbox></object>
+		</annotation>`,
+		// Named like its missing image b.jpg.
+		"b.xml": `<annotation>
+			<object><name>bob</name><bndbox><xmin>10</xmin><ymin>10</ymin><xmax>90</xmax><ymax>90</ymax></bndbox></object>
+		</annotation>`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec, _ := newFakeRecognizer(t, nil)
+	results, err := rec.ImportVOC(dir, ImportOptions{Skip: []string{"person"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		image  string
+		id     string
+		status ImportStatus
+	}{
+		{"a.png", "alice", Enrolled},
+		{"a.png", "", Skipped},
+		{"a.png", "person", Skipped},
+		{"b.jpg", "bob", ImageFailed},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, r := range results {
+		if r.Image != want[i].image || r.Id != want[i].id || r.Status != want[i].status {
+			t.Errorf("result %d is %s %q %s, want %s %q %s", i, r.Image, r.Id, r.Status, want[i].image, want[i].id, want[i].status)
+		}
+	}
+}
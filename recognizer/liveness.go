@@ -0,0 +1,179 @@
+package recognizer
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// LivenessOptions configures the liveness check of tracks. Zero values are
+// replaced by defaults suited to a camera at 10 to 30 frames per second.
+type LivenessOptions struct {
+	// BlinkThreshold is the eye aspect ratio below which the eyes are
+	// closed, open eyes are around 0.3.
+	BlinkThreshold float64
+	// MaxBlinkFrames is the number of consecutive frames with closed eyes
+	// above which they are held closed rather than blinking.
+	MaxBlinkFrames int
+	// MinMotion is the head motion, relative to the distance between the
+	// eyes, for which the motion score is full.
+	MinMotion float64
+}
+
+// Liveness tells how likely a track is a live person rather than a photo
+// or a screen. It needs the 68 landmarks of goFace.ShapePredictor68Model,
+// tracks of faces with fewer landmarks keep a zero Liveness.
+type Liveness struct {
+	// Score is 0.6 once the track blinked plus up to 0.4 for head motion,
+	// between 0 and 1.
+	Score float64
+	// Blinks is the number of blinks seen.
+	Blinks int
+	// Motion is the spread of the nose position relative to the eyes over
+	// the frames, which moving a photo doesn't change unlike turning the
+	// head.
+	Motion float64
+	// EyeAspectRatio is the one of the last frame.
+	EyeAspectRatio float64
+	// Frames is the number of frames with landmarks.
+	Frames int
+}
+
+// ErrNoLandmarks is returned by CheckLiveness if the faces don't have the
+// 68 landmarks needed for blink detection.
+var ErrNoLandmarks = errors.New("Liveness needs the 68 landmarks of goFace.ShapePredictor68Model")
+
+// liveness accumulates the landmarks of a track.
+type liveness struct {
+	opt    LivenessOptions
+	closed int
+	// sum and sumSq of the nose offsets to compute their spread.
+	sum, sumSq [2]float64
+	result     Liveness
+}
+
+func newLiveness(opt LivenessOptions) *liveness {
+	if opt.BlinkThreshold <= 0 {
+		opt.BlinkThreshold = 0.21
+	}
+	if opt.MaxBlinkFrames <= 0 {
+		opt.MaxBlinkFrames = 8
+	}
+	if opt.MinMotion <= 0 {
+		opt.MinMotion = 0.02
+	}
+	return &liveness{opt: opt}
+}
+
+// update adds the landmarks of a frame, ignored unless there are 68.
+func (l *liveness) update(shapes []image.Point) {
+	ear, ok := EyeAspectRatio(shapes)
+	if !ok {
+		return
+	}
+	r := &l.result
+	r.Frames++
+	r.EyeAspectRatio = ear
+	if ear < l.opt.BlinkThreshold {
+		l.closed++
+	} else {
+		if l.closed > 0 && l.closed <= l.opt.MaxBlinkFrames {
+			r.Blinks++
+		}
+		l.closed = 0
+	}
+
+	// Offset of the nose tip to the middle of the eyes, in units of the
+	// distance between the eyes, so that moving or scaling a photo keeps
+	// it unchanged.
+	left, right := center(shapes[36:42]), center(shapes[42:48])
+	eyes := math.Hypot(right[0]-left[0], right[1]-left[1])
+	if eyes == 0 {
+		return
+	}
+	nose := shapes[30]
+	offset := [2]float64{
+		(float64(nose.X) - (left[0]+right[0])/2) / eyes,
+		(float64(nose.Y) - (left[1]+right[1])/2) / eyes,
+	}
+	n := float64(r.Frames)
+	var variance float64
+	for i, v := range offset {
+		l.sum[i] += v
+		l.sumSq[i] += v * v
+		mean := l.sum[i] / n
+		variance += math.Max(l.sumSq[i]/n-mean*mean, 0)
+	}
+	r.Motion = math.Sqrt(variance)
+
+	r.Score = 0.4 * math.Min(r.Motion/l.opt.MinMotion, 1)
+	if r.Blinks > 0 {
+		r.Score += 0.6
+	}
+}
+
+/*
+EyeAspectRatio returns the mean eye aspect ratio of both eyes of 68 landmarks,
+the height of the eye over its width, which drops close to 0 when it closes.
+*/
+func EyeAspectRatio(Shapes []image.Point) (float64, bool) {
+	if len(Shapes) != 68 {
+		return 0, false
+	}
+	ear := func(p []image.Point) float64 {
+		dist := func(a, b image.Point) float64 {
+			return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+		}
+		width := dist(p[0], p[3])
+		if width == 0 {
+			return 0
+		}
+		return (dist(p[1], p[5]) + dist(p[2], p[4])) / (2 * width)
+	}
+	return (ear(Shapes[36:42]) + ear(Shapes[42:48])) / 2, true
+}
+
+func center(Points []image.Point) [2]float64 {
+	var c [2]float64
+	for _, p := range Points {
+		c[0] += float64(p.X)
+		c[1] += float64(p.Y)
+	}
+	c[0] /= float64(len(Points))
+	c[1] /= float64(len(Points))
+	return c
+}
+
+/*
+CheckLiveness tracks the faces of consecutive frames, e.g. two to three
+seconds of a camera, and returns the track seen in most frames with its
+identity and liveness. The recognizer must be created with
+Option.ShapePredictor set to goFace.ShapePredictor68Model.
+*/
+func (_this *Recognizer) CheckLiveness(Frames []image.Image, Opt TrackerOptions) (Track, error) {
+	tracker := NewTracker(Opt)
+	landmarks := false
+	for _, img := range Frames {
+		faces, err := _this.classifyImage(img, true)
+		if err != nil {
+			return Track{}, err
+		}
+		for _, f := range faces {
+			landmarks = landmarks || len(f.Shapes) == 68
+		}
+		tracker.Update(faces)
+	}
+	var best Track
+	for _, track := range tracker.Flush() {
+		if track.Frames > best.Frames {
+			best = track
+		}
+	}
+	if best.Frames == 0 {
+		return Track{}, errors.New("Can't find a face in the frames")
+	}
+	if !landmarks {
+		return best, ErrNoLandmarks
+	}
+	return best, nil
+}
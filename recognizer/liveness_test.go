@@ -0,0 +1,93 @@
+package recognizer
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// landmarks returns 68 landmarks whose eyes are 20 pixels wide and open by
+// the given height above and below their middle, with the nose tip at
+// noseX.
+func landmarks(open, noseX int) []image.Point {
+	shapes := make([]image.Point, 68)
+	for _, eye := range []struct{ first, x int }{{36, 100}, {42, 140}} {
+		p := shapes[eye.first : eye.first+6]
+		p[0], p[3] = image.Pt(eye.x, 100), image.Pt(eye.x+20, 100)
+		p[1], p[2] = image.Pt(eye.x+7, 100-open), image.Pt(eye.x+13, 100-open)
+		p[5], p[4] = image.Pt(eye.x+7, 100+open), image.Pt(eye.x+13, 100+open)
+	}
+	shapes[30] = image.Pt(noseX, 130)
+	return shapes
+}
+
+func TestEyeAspectRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		shapes []image.Point
+		ear    float64
+		ok     bool
+	}{
+		{"open", landmarks(3, 130), 0.3, true},
+		{"closed", landmarks(1, 130), 0.1, true},
+		{"shut", landmarks(0, 130), 0, true},
+		{"no eye width", make([]image.Point, 68), 0, true},
+		{"5 landmarks", landmarks(3, 130)[:5], 0, false},
+		{"no landmarks", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ear, ok := EyeAspectRatio(tt.shapes)
+			if ok != tt.ok || math.Abs(ear-tt.ear) > 1e-9 {
+				t.Errorf("got %v (%v), want %v (%v)", ear, ok, tt.ear, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLivenessBlinks(t *testing.T) {
+	open, closed := landmarks(3, 130), landmarks(1, 130)
+	repeat := func(shapes []image.Point, n int) [][]image.Point {
+		frames := make([][]image.Point, n)
+		for i := range frames {
+			frames[i] = shapes
+		}
+		return frames
+	}
+	concat := func(parts ...[][]image.Point) [][]image.Point {
+		var frames [][]image.Point
+		for _, p := range parts {
+			frames = append(frames, p...)
+		}
+		return frames
+	}
+
+	tests := []struct {
+		name   string
+		frames [][]image.Point
+		blinks int
+		count  int
+		score  float64
+	}{
+		{"open eyes", repeat(open, 10), 0, 10, 0},
+		{"closed eyes", repeat(closed, 10), 0, 10, 0},
+		{"blink", concat(repeat(open, 3), repeat(closed, 2), repeat(open, 3)), 1, 8, 0.6},
+		{"two blinks", concat(repeat(open, 2), repeat(closed, 1), repeat(open, 2), repeat(closed, 3), repeat(open, 1)), 2, 9, 0.6},
+		{"held closed", concat(repeat(open, 2), repeat(closed, 9), repeat(open, 2)), 0, 13, 0},
+		{"closing", concat(repeat(open, 3), repeat(closed, 2)), 0, 5, 0},
+		{"missing landmarks", concat(repeat(open, 2), repeat(closed, 1), repeat(nil, 3), repeat(open[:5], 2), repeat(open, 1)), 1, 4, 0.6},
+		{"head motion", [][]image.Point{landmarks(3, 120), landmarks(3, 140)}, 0, 2, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLiveness(LivenessOptions{})
+			for _, shapes := range tt.frames {
+				l.update(shapes)
+			}
+			r := l.result
+			if r.Blinks != tt.blinks || r.Frames != tt.count || math.Abs(r.Score-tt.score) > 1e-9 {
+				t.Errorf("got %d blinks in %d frames, score %v, want %d in %d, score %v", r.Blinks, r.Frames, r.Score, tt.blinks, tt.count, tt.score)
+			}
+		})
+	}
+}
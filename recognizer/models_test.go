@@ -0,0 +1,103 @@
+package recognizer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+func TestClassifyFaceOtherModel(t *testing.T) {
+	rec, _ := newFakeRecognizer(t, nil)
+	rec.dataset = []Data{{Id: "alice", Descriptor: goFace.Descriptor{}}}
+	rec.SetSamples()
+	// The face matches alice but was embedded by another model.
+	face := goFace.Face{Descriptor: goFace.Descriptor{}, Model: "retrained"}
+
+	tests := []struct {
+		name           string
+		includeUnknown bool
+		ok             bool
+	}{
+		{"dropped", false, false},
+		{"unknown", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := rec.classifyFace(face, tt.includeUnknown)
+			if ok != tt.ok {
+				t.Fatalf("returned %v, want %v", ok, tt.ok)
+			}
+			if ok && (f.Id != Unknown || f.Distance != -1 || f.Model != "retrained") {
+				t.Errorf("got %q at %v of model %q, want %q at -1", f.Id, f.Distance, f.Model, Unknown)
+			}
+		})
+	}
+}
+
+func TestSetSamplesModel(t *testing.T) {
+	var near goFace.Descriptor
+	near[0] = 0.1
+	// Bob's sample is nearer the query but was embedded by another model.
+	dataset := []Data{
+		{Id: "alice", Descriptor: goFace.Descriptor{}, Model: goFace.DefaultModel},
+		{Id: "bob", Descriptor: near, Model: "retrained"},
+	}
+
+	tests := []struct {
+		name  string
+		model string
+		want  string
+	}{
+		{"default model", "", "alice"},
+		{"other model", "retrained", "bob"},
+		{"no sample", "unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := newFakeRecognizer(t, nil)
+			if tt.model != "" {
+				if err := rec.SetModel(tt.model); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec.dataset = dataset
+			rec.SetSamples()
+			person, _, ok := rec.classify(near)
+			if ok != (tt.want != "") || person.Id != tt.want {
+				t.Errorf("classified as %q (%v), want %q", person.Id, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDatasetModels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.json")
+	// Datasets saved before embedding models were tracked have no Model.
+	legacy := `[{"Id":"alice","Descriptor":[]},{"Id":"bob","Descriptor":[],"Model":"retrained"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0666); err != nil {
+		t.Fatal(err)
+	}
+	rec, _ := newFakeRecognizer(t, nil)
+	if err := rec.LoadDataset(path); err != nil {
+		t.Fatal(err)
+	}
+	models := rec.DatasetModels()
+	if len(models) != 2 || models[goFace.DefaultModel] != 1 || models["retrained"] != 1 {
+		t.Errorf("got models %v, want 1 sample of %s and 1 of retrained", models, goFace.DefaultModel)
+	}
+
+	// The model survives a save and load.
+	if err := rec.SaveDataset(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := newFakeRecognizer(t, nil)
+	if err := loaded.LoadDataset(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded.SetSamples()
+	if person, _, ok := loaded.classify(goFace.Descriptor{}); !ok || person.Id != "alice" {
+		t.Errorf("classified as %q (%v), want alice", person.Id, ok)
+	}
+}
@@ -0,0 +1,167 @@
+package recognizer
+
+import (
+	"math"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+// prototypeKey identifies the samples of an identity computed by a model.
+type prototypeKey struct {
+	Id    string
+	Model string
+}
+
+// prototype tracks the samples of an identity and their sum, so that the
+// mean descriptor is kept up to date as samples are added and replaced.
+type prototype struct {
+	samples []int
+	sum     [128]float64
+}
+
+func (p *prototype) add(d goFace.Descriptor) {
+	for i := range d {
+		p.sum[i] += float64(d[i])
+	}
+}
+
+func (p *prototype) remove(d goFace.Descriptor) {
+	for i := range d {
+		p.sum[i] -= float64(d[i])
+	}
+}
+
+func (p *prototype) mean() goFace.Descriptor {
+	var d goFace.Descriptor
+	for i := range d {
+		d[i] = float32(p.sum[i] / float64(len(p.samples)))
+	}
+	return d
+}
+
+/*
+Prototype returns the mean descriptor of the samples of the identity
+computed by the embedding model in use
+*/
+func (_this *Recognizer) Prototype(Id string) (goFace.Descriptor, bool) {
+	p, ok := _this.prototypes[prototypeKey{Id, _this.opt.Model}]
+	if !ok || len(p.samples) == 0 {
+		return goFace.Descriptor{}, false
+	}
+	return p.mean(), true
+}
+
+/*
+Prototypes returns the mean descriptor of every identity, in the order of
+the dataset, for the embedding model in use
+*/
+func (_this *Recognizer) Prototypes() []Data {
+	prototypes := make([]Data, 0, len(_this.prototypes))
+	seen := make(map[string]bool)
+	for _, f := range _this.dataset {
+		if seen[f.Id] {
+			continue
+		}
+		seen[f.Id] = true
+		if d, ok := _this.Prototype(f.Id); ok {
+			prototypes = append(prototypes, Data{Id: f.Id, Descriptor: d, Model: _this.opt.Model})
+		}
+	}
+	return prototypes
+}
+
+/*
+PruneDataset drops the redundant samples of the dataset and caps the samples
+of every identity as AddImageToDataset does, e.g. after LoadDataset. Call
+SetSamples afterwards.
+*/
+func (_this *Recognizer) PruneDataset() {
+	dataset := _this.dataset
+	_this.dataset = make([]Data, 0, len(dataset))
+	_this.prototypes = make(map[prototypeKey]*prototype)
+	for _, f := range dataset {
+		_this.addSample(f)
+	}
+}
+
+/*
+appendSample appends the sample to the dataset as is
+*/
+func (_this *Recognizer) appendSample(F Data) {
+	F.Model = modelOf(F.Model)
+	key := prototypeKey{F.Id, F.Model}
+	p, ok := _this.prototypes[key]
+	if !ok {
+		p = &prototype{}
+		_this.prototypes[key] = p
+	}
+	p.samples = append(p.samples, len(_this.dataset))
+	p.add(F.Descriptor)
+	_this.dataset = append(_this.dataset, F)
+}
+
+/*
+addSample adds the sample to the dataset unless it is redundant with
+DedupEpsilon. Once the identity has MaxSamples, the least diverse of its
+samples and the new one is dropped, the new one taking its place.
+*/
+func (_this *Recognizer) addSample(F Data) bool {
+	F.Model = modelOf(F.Model)
+	p := _this.prototypes[prototypeKey{F.Id, F.Model}]
+	if p == nil {
+		_this.appendSample(F)
+		return true
+	}
+	candidates := make([]goFace.Descriptor, 0, len(p.samples)+1)
+	for _, i := range p.samples {
+		candidates = append(candidates, _this.dataset[i].Descriptor)
+		if _this.distance(_this.dataset[i].Descriptor, F.Descriptor) < float64(_this.opt.DedupEpsilon) {
+			return false
+		}
+	}
+	if _this.opt.MaxSamples <= 0 || len(p.samples) < _this.opt.MaxSamples {
+		_this.appendSample(F)
+		return true
+	}
+	candidates = append(candidates, F.Descriptor)
+	drop := _this.leastDiverse(candidates)
+	if drop == len(p.samples) {
+		return false
+	}
+	i := p.samples[drop]
+	p.remove(_this.dataset[i].Descriptor)
+	p.add(F.Descriptor)
+	_this.dataset[i] = F
+	return true
+}
+
+/*
+leastDiverse returns which of the descriptors is the most redundant: of the
+two closest ones, the one nearer to the others on average
+*/
+func (_this *Recognizer) leastDiverse(Descriptors []goFace.Descriptor) int {
+	n := len(Descriptors)
+	dists := make([][]float64, n)
+	for i := range dists {
+		dists[i] = make([]float64, n)
+	}
+	a, b, closest := 0, 0, math.Inf(1)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dist := _this.distance(Descriptors[i], Descriptors[j])
+			dists[i][j], dists[j][i] = dist, dist
+			if dist < closest {
+				a, b, closest = i, j, dist
+			}
+		}
+	}
+	var sumA, sumB float64
+	for k := 0; k < n; k++ {
+		sumA += dists[a][k]
+		sumB += dists[b][k]
+	}
+	if sumB < sumA {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,115 @@
+package recognizer
+
+import (
+	"testing"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/recognizer/fake"
+)
+
+func descriptor(v ...float32) goFace.Descriptor {
+	var d goFace.Descriptor
+	copy(d[:], v)
+	return d
+}
+
+func TestAddSampleDedup(t *testing.T) {
+	// The second sample points the same way as the first one but is twice
+	// as long: redundant in cosine, distant in squared euclidean.
+	tests := []struct {
+		name       string
+		classifier Classifier
+		added      bool
+	}{
+		{"cosine", goFace.NewClassifier(goFace.ClassifierOptions{Metric: goFace.Cosine}), false},
+		{"squared euclidean", fake.New(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := fake.New()
+			rec, err := New(&Option{Detector: fb, Embedder: fb, Classifier: tt.classifier, DedupEpsilon: 0.01})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.addSample(Data{Id: "alice", Descriptor: descriptor(1)})
+			if added := rec.addSample(Data{Id: "alice", Descriptor: descriptor(2)}); added != tt.added {
+				t.Errorf("added %v, want %v", added, tt.added)
+			}
+			// Other identities are never redundant.
+			if !rec.addSample(Data{Id: "bob", Descriptor: descriptor(1)}) {
+				t.Error("dropped the first sample of bob")
+			}
+		})
+	}
+}
+
+func TestAddSampleMaxSamples(t *testing.T) {
+	rec, _ := newFakeRecognizer(t, &Option{MaxSamples: 3})
+	for _, d := range []goFace.Descriptor{descriptor(1), descriptor(0, 1), descriptor(0.9, 0.1)} {
+		rec.addSample(Data{Id: "alice", Descriptor: d})
+	}
+
+	tests := []struct {
+		name  string
+		add   goFace.Descriptor
+		added bool
+		want  []goFace.Descriptor
+	}{
+		// The third sample is nearer the others than the first one.
+		{"replaces", descriptor(0, 0, 1), true, []goFace.Descriptor{descriptor(1), descriptor(0, 1), descriptor(0, 0, 1)}},
+		{"dropped", descriptor(0.95, 0.05), false, []goFace.Descriptor{descriptor(1), descriptor(0, 1), descriptor(0, 0, 1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if added := rec.addSample(Data{Id: "alice", Descriptor: tt.add}); added != tt.added {
+				t.Errorf("added %v, want %v", added, tt.added)
+			}
+			if len(rec.dataset) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(rec.dataset), len(tt.want))
+			}
+			for i, f := range rec.dataset {
+				if f.Descriptor != tt.want[i] {
+					t.Errorf("sample %d is %v, want %v", i, f.Descriptor[:3], tt.want[i][:3])
+				}
+			}
+			if mean, _ := rec.Prototype("alice"); mean != descriptor(1.0/3, 1.0/3, 1.0/3) {
+				t.Errorf("got prototype %v, want the mean of the samples", mean[:3])
+			}
+		})
+	}
+}
+
+func TestPruneDataset(t *testing.T) {
+	rec, _ := newFakeRecognizer(t, &Option{DedupEpsilon: 0.01, MaxSamples: 2})
+	rec.dataset = []Data{
+		{Id: "alice", Descriptor: descriptor(1)},
+		{Id: "bob", Descriptor: descriptor(0, 1)},
+		{Id: "alice", Descriptor: descriptor(1, 0.01)},
+		{Id: "alice", Descriptor: descriptor(0, 0, 1)},
+		{Id: "alice", Descriptor: descriptor(0.1, 0, 0.9)},
+	}
+	rec.PruneDataset()
+
+	want := []Data{
+		{Id: "alice", Descriptor: descriptor(1), Model: goFace.DefaultModel},
+		{Id: "bob", Descriptor: descriptor(0, 1), Model: goFace.DefaultModel},
+		{Id: "alice", Descriptor: descriptor(0, 0, 1), Model: goFace.DefaultModel},
+	}
+	if len(rec.dataset) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(rec.dataset), len(want))
+	}
+	for i := range want {
+		if rec.dataset[i] != want[i] {
+			t.Errorf("sample %d is %q %v, want %q %v", i, rec.dataset[i].Id, rec.dataset[i].Descriptor[:3], want[i].Id, want[i].Descriptor[:3])
+		}
+	}
+	prototypes := rec.Prototypes()
+	if len(prototypes) != 2 || prototypes[0].Id != "alice" || prototypes[0].Descriptor != descriptor(0.5, 0, 0.5) {
+		t.Fatalf("got prototypes %v, want alice at the mean of her samples and bob", prototypes)
+	}
+	for _, p := range prototypes {
+		if p.Model != goFace.DefaultModel {
+			t.Errorf("prototype of %s has model %q, want %q", p.Id, p.Model, goFace.DefaultModel)
+		}
+	}
+}
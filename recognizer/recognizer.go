@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"math"
 	"os"
 	"path/filepath"
 
@@ -16,12 +17,25 @@ import (
 type Data struct {
 	Id         string
 	Descriptor goFace.Descriptor
+	// Model is the ID of the embedding model which computed Descriptor,
+	// goFace.DefaultModel if empty.
+	Model string
 }
 
+// Unknown is the Id of classified faces that match no identity, returned
+// only if Option.IncludeUnknown is set.
+const Unknown = "unknown"
+
 // Face holds coordinates and descriptor of the human face.
 type Face struct {
 	Data
 	Rectangle image.Rectangle
+	// Distance is the distance to the matched sample, squared euclidean
+	// unless the classifier has another metric, -1 for Unknown faces.
+	Distance float32
+	// Shapes are the landmarks of the face, 68 points with
+	// Option.ShapePredictor set to goFace.ShapePredictor68Model.
+	Shapes []image.Point
 }
 
 type Option struct {
@@ -29,6 +43,63 @@ type Option struct {
 	UseCNN    bool
 	UseGray   bool
 	ModelDir  string
+	// ShapePredictor is the shape predictor file of ModelDir,
+	// goFace.ShapePredictorModel if empty. Set goFace.ShapePredictor68Model
+	// for CheckLiveness and the liveness of tracks.
+	ShapePredictor string
+	// Limits bounds the size of loaded images, goFace.DefaultLimits if nil.
+	Limits *goFace.Limits
+	// Detector, Embedder and Classifier replace the dlib implementation,
+	// which is loaded from ModelDir only if one of them is nil. Use
+	// goFace.PicoDetector to detect faces in builds without cgo, or the fake
+	// package to test code without models.
+	Detector   goFace.Detector
+	Embedder   Embedder
+	Classifier Classifier
+	// K, Strategy and Metric make the default classifier a
+	// goFace.Classifier choosing among the K nearest samples with Strategy,
+	// measuring distances, and so Tolerance, with Metric. Zero values pick
+	// the nearest sample on squared euclidean distance, like dlib.
+	K        int
+	Strategy goFace.Strategy
+	Metric   goFace.Metric
+	// IndexThreshold makes the default classifier a goFace.Classifier
+	// searching an approximate nearest neighbor index once the dataset has
+	// more samples, for large galleries. The index is saved alongside the
+	// dataset.
+	IndexThreshold int
+	// AdaptiveTolerance learns the tolerance of every identity from the
+	// spread of its samples in SetSamples, bounded by MinTolerance and
+	// MaxTolerance (default half and one and a half Tolerance). Identities
+	// with a single sample use Tolerance.
+	AdaptiveTolerance bool
+	MinTolerance      float32
+	MaxTolerance      float32
+	// Margin is how much closer than the nearest sample of any other
+	// identity the match must be, 0 disables the check.
+	Margin float32
+	// IncludeUnknown makes Classify and ClassifyMultiples return faces that
+	// match no identity, or were embedded by another model than Model, with
+	// the Unknown Id instead of dropping them.
+	IncludeUnknown bool
+	// DedupEpsilon drops added samples closer than it to a sample of the
+	// same identity, in the metric of the classifier, 0 keeps them all.
+	DedupEpsilon float32
+	// MaxSamples caps the samples of every identity, keeping the most
+	// diverse ones, 0 means no limit.
+	MaxSamples int
+	// Models registers additional dlib embedding models, by ID, from the
+	// given files.
+	Models map[string]string
+	// Model is the ID of the embedding model used by the dlib embedder,
+	// goFace.DefaultModel if empty. Only dataset samples computed by this
+	// model are classified, so that a dataset can be re-embedded with a
+	// new model while the old one is still in use.
+	Model string
+	// Encoding compacts the descriptors of saved datasets and of the index
+	// of IndexThreshold, at some loss of accuracy which can be measured
+	// with goFace.MeasureQuantization.
+	Encoding goFace.Encoding
 }
 
 /*
@@ -36,9 +107,27 @@ A Recognizer creates face descriptors for provided images and
 classifies them into categories.
 */
 type Recognizer struct {
-	opt     *Option
-	rec     *goFace.Recognizer
-	dataset []Data
+	opt        *Option
+	rec        *goFace.Recognizer
+	detector   goFace.Detector
+	embedder   Embedder
+	classifier Classifier
+	dataset    []Data
+	// members holds the dataset indexes of every category passed to the
+	// classifier by SetSamples, one category per identity.
+	members [][]int
+	// samples holds the dataset index of every sample passed to the
+	// classifier by SetSamples, and categories its category.
+	samples    []int
+	categories []int32
+	// bySample is set if the classifier was given a category per sample
+	// instead of per identity.
+	bySample bool
+	// tolerances holds the tolerance of every category.
+	tolerances []float32
+	// prototypes holds the samples and mean descriptor of every identity
+	// and model.
+	prototypes map[prototypeKey]*prototype
 }
 
 func New(opt ...*Option) (*Recognizer, error) {
@@ -49,47 +138,155 @@ func New(opt ...*Option) (*Recognizer, error) {
 	if cfg.Tolerance == 0 {
 		cfg.Tolerance = 0.4
 	}
+	if cfg.MinTolerance == 0 {
+		cfg.MinTolerance = cfg.Tolerance / 2
+	}
+	if cfg.MaxTolerance == 0 {
+		cfg.MaxTolerance = cfg.Tolerance * 1.5
+	}
 	if cfg.ModelDir == "" {
 		cfg.ModelDir = "models"
 	}
+	if cfg.ShapePredictor == "" {
+		cfg.ShapePredictor = goFace.ShapePredictorModel
+	}
+	if cfg.Model == "" {
+		cfg.Model = goFace.DefaultModel
+	}
+	if cfg.Limits == nil {
+		limits := goFace.DefaultLimits
+		cfg.Limits = &limits
+	}
 	rec := &Recognizer{
-		opt:     cfg,
-		dataset: make([]Data, 0),
+		opt:        cfg,
+		detector:   cfg.Detector,
+		embedder:   cfg.Embedder,
+		classifier: cfg.Classifier,
+		dataset:    make([]Data, 0),
+		prototypes: make(map[prototypeKey]*prototype),
 	}
-	r, err := goFace.NewRecognizer(cfg.ModelDir)
-	if err == nil {
-		rec.rec = r
+	var err error
+	if rec.detector == nil || rec.embedder == nil || rec.classifier == nil {
+		var r *goFace.Recognizer
+		r, err = goFace.NewRecognizerWithShapePredictor(cfg.ModelDir, cfg.ShapePredictor)
+		if err == nil {
+			r.SetLimits(*cfg.Limits)
+			rec.rec = r
+			for id, path := range cfg.Models {
+				if err := r.AddModel(id, path); err != nil {
+					r.Close()
+					return nil, fmt.Errorf("Can't load model %s: %w", id, err)
+				}
+			}
+			if r, err = r.WithModel(cfg.Model); err != nil {
+				rec.rec.Close()
+				return nil, err
+			}
+		} else if errors.Is(err, goFace.ErrCgoDisabled) && (cfg.Detector != nil || cfg.Embedder != nil) {
+			// Everything but the missing dlib parts works without cgo,
+			// those return goFace.ErrCgoDisabled.
+			err = nil
+		}
+		backend := dlibBackend{rec: r, useCNN: cfg.UseCNN}
+		if rec.detector == nil {
+			rec.detector = backend
+		}
+		if rec.embedder == nil {
+			rec.embedder = backend
+		}
+		custom := cfg.K > 0 || cfg.Strategy != goFace.Vote || cfg.Metric != goFace.SquaredEuclidean || cfg.IndexThreshold > 0
+		if rec.classifier == nil && r != nil && !custom {
+			rec.classifier = r
+		} else if rec.classifier == nil {
+			rec.classifier = goFace.NewClassifier(goFace.ClassifierOptions{
+				K:              max(cfg.K, 1),
+				Metric:         cfg.Metric,
+				Strategy:       cfg.Strategy,
+				IndexThreshold: cfg.IndexThreshold,
+				Index:          goFace.IndexOptions{Encoding: cfg.Encoding},
+			})
+		}
 	}
 	return rec, err
 }
 
+/*
+SetModel changes the embedding model used by the dlib embedder and the
+dataset samples classified. Call SetSamples afterwards.
+*/
+func (_this *Recognizer) SetModel(Model string) error {
+	if backend, ok := _this.embedder.(dlibBackend); ok && _this.rec != nil {
+		view, err := _this.rec.WithModel(Model)
+		if err != nil {
+			return err
+		}
+		backend.rec = view
+		_this.embedder = backend
+		if _, ok := _this.detector.(dlibBackend); ok {
+			_this.detector = backend
+		}
+	}
+	_this.opt.Model = Model
+	return nil
+}
+
+/*
+DatasetModels returns the number of dataset samples of every embedding model,
+e.g. to follow the re-embedding of a dataset
+*/
+func (_this *Recognizer) DatasetModels() map[string]int {
+	models := make(map[string]int)
+	for _, f := range _this.dataset {
+		models[f.Model]++
+	}
+	return models
+}
+
+/*
+modelOf returns the embedding model of a descriptor, the default one if not set
+*/
+func modelOf(Model string) string {
+	if Model == "" {
+		return goFace.DefaultModel
+	}
+	return Model
+}
+
 /*
 Close frees resources taken by the Recognizer. Safe to call multiple
 times. Don't use Recognizer after close call.
 */
 func (_this *Recognizer) Close() {
-	_this.rec.Close()
+	if _this.rec != nil {
+		_this.rec.Close()
+	}
 }
 
 /*
-AddImageToDataset add a sample image to the dataset
+loadFaceImage loads the image to recognize, converted to grayscale if UseGray is set
 */
-func (_this *Recognizer) AddImageToDataset(Path string, Id string) error {
-	file := Path
-	var err error
+func (_this *Recognizer) loadFaceImage(Path string) (image.Image, error) {
+	img, err := _this.LoadImage(Path)
+	if err != nil {
+		return nil, err
+	}
 	if _this.opt.UseGray {
-		file, err = _this.createTempGrayFile(file, Id)
-		if err != nil {
-			return err
-		}
-		defer os.Remove(file)
+		img = _this.GrayScale(img)
 	}
-	var faces []goFace.Face
-	if _this.opt.UseCNN {
-		faces, err = _this.rec.RecognizeFileCNN(file)
-	} else {
-		faces, err = _this.rec.RecognizeFile(file)
+	return img, nil
+}
+
+/*
+AddImageToDataset add a sample image to the dataset. The sample is dropped
+if redundant with DedupEpsilon, or replaces a less diverse one if the
+identity already has MaxSamples.
+*/
+func (_this *Recognizer) AddImageToDataset(Path string, Id string) error {
+	img, err := _this.loadFaceImage(Path)
+	if err != nil {
+		return err
 	}
+	faces, err := _this.embedder.RecognizeImage(img)
 	if err != nil {
 		return err
 	}
@@ -102,81 +299,249 @@ func (_this *Recognizer) AddImageToDataset(Path string, Id string) error {
 	f := Data{}
 	f.Id = Id
 	f.Descriptor = faces[0].Descriptor
-	_this.dataset = append(_this.dataset, f)
+	f.Model = modelOf(faces[0].Model)
+	_this.addSample(f)
 	return nil
 }
 
 /*
 SetSamples sets known descriptors so you can classify the new ones.
+Every identity of the dataset is a category of a goFace.Classifier, which
+votes among identities. Other classifiers, such as dlib's top-10 vote, get a
+category per sample so that they pick the nearest one. Only the samples of
+the embedding model in use are set.
 */
 func (_this *Recognizer) SetSamples() {
 	var samples []goFace.Descriptor
 	var avengers []int32
+	categories := make(map[string]int32)
+	_, byIdentity := _this.classifier.(*goFace.Classifier)
+	_this.bySample = !byIdentity
+	_this.members = _this.members[:0]
+	_this.samples = _this.samples[:0]
+	_this.categories = _this.categories[:0]
 	for i, f := range _this.dataset {
+		if modelOf(f.Model) != _this.opt.Model {
+			continue
+		}
+		cat, ok := categories[f.Id]
+		if !ok {
+			cat = int32(len(_this.members))
+			categories[f.Id] = cat
+			_this.members = append(_this.members, nil)
+		}
+		_this.members[cat] = append(_this.members[cat], i)
+		_this.samples = append(_this.samples, i)
+		_this.categories = append(_this.categories, cat)
 		samples = append(samples, f.Descriptor)
-		avengers = append(avengers, int32(i))
+		if _this.bySample {
+			cat = int32(len(_this.samples) - 1)
+		}
+		avengers = append(avengers, cat)
+	}
+	_this.tolerances = _this.tolerances[:0]
+	for _, members := range _this.members {
+		_this.tolerances = append(_this.tolerances, _this.learnTolerance(members))
 	}
-	_this.rec.SetSamples(samples, avengers)
+	_this.classifier.SetSamples(samples, avengers)
 }
 
 /*
-RecognizeSingle returns face if it's the only face on the image or nil otherwise.
-Only JPEG format is currently supported.
+learnTolerance returns the tolerance of the identity with the given samples:
+mean plus two standard deviations of the distances between its samples
 */
-func (_this *Recognizer) RecognizeSingle(Path string) (goFace.Face, error) {
-	file := Path
-	var err error
-	if _this.opt.UseGray {
-		file, err = _this.createTempGrayFile(file, "64ab59ac42d69274f06eadb11348969e")
-		if err != nil {
-			return goFace.Face{}, err
+func (_this *Recognizer) learnTolerance(Members []int) float32 {
+	if !_this.opt.AdaptiveTolerance || len(Members) < 2 {
+		return _this.opt.Tolerance
+	}
+	var sum, sumSq float64
+	var n int
+	for i := range Members {
+		for j := i + 1; j < len(Members); j++ {
+			dist := _this.distance(_this.dataset[Members[i]].Descriptor, _this.dataset[Members[j]].Descriptor)
+			sum += dist
+			sumSq += dist * dist
+			n++
 		}
-		defer os.Remove(file)
 	}
-	var idFace *goFace.Face
-	if _this.opt.UseCNN {
-		idFace, err = _this.rec.RecognizeSingleFileCNN(file)
-	} else {
-		idFace, err = _this.rec.RecognizeSingleFile(file)
+	mean := sum / float64(n)
+	std := math.Sqrt(math.Max(sumSq/float64(n)-mean*mean, 0))
+	tolerance := float32(mean + 2*std)
+	if tolerance < _this.opt.MinTolerance {
+		tolerance = _this.opt.MinTolerance
 	}
-	if err != nil {
-		return goFace.Face{}, fmt.Errorf("Can't recognize: %v", err)
+	if tolerance > _this.opt.MaxTolerance {
+		tolerance = _this.opt.MaxTolerance
+	}
+	return tolerance
+}
 
+/*
+classify returns the dataset sample nearest to the descriptor among the
+samples of the identity chosen by the classifier, and its distance. The
+match must be within the tolerance of the identity and beat the other
+identities by Margin.
+*/
+func (_this *Recognizer) classify(Descriptor goFace.Descriptor) (Data, float32, bool) {
+	maxTolerance := _this.opt.Tolerance
+	if _this.opt.AdaptiveTolerance {
+		maxTolerance = _this.opt.MaxTolerance
+	}
+	cat := _this.classifier.ClassifyThreshold(Descriptor, maxTolerance)
+	if _this.bySample && cat >= 0 && cat < len(_this.categories) {
+		cat = int(_this.categories[cat])
+	}
+	if cat < 0 || cat >= len(_this.members) {
+		return Data{}, -1, false
+	}
+	best, bestDist := -1, math.Inf(1)
+	for _, i := range _this.members[cat] {
+		dist := _this.distance(_this.dataset[i].Descriptor, Descriptor)
+		if dist < bestDist {
+			best, bestDist = i, dist
+		}
 	}
-	if idFace == nil {
+	if cat < len(_this.tolerances) && bestDist > float64(_this.tolerances[cat]) {
+		return Data{}, -1, false
+	}
+	if _this.opt.Margin > 0 && _this.runnerUp(Descriptor, cat)-bestDist < float64(_this.opt.Margin) {
+		return Data{}, -1, false
+	}
+	return _this.dataset[best], float32(bestDist), true
+}
+
+/*
+tolerance returns the tolerance classify matches the identity with, learned
+with AdaptiveTolerance, Option.Tolerance otherwise
+*/
+func (_this *Recognizer) tolerance(Id string) float32 {
+	for cat, members := range _this.members {
+		if len(members) > 0 && _this.dataset[members[0]].Id == Id && cat < len(_this.tolerances) {
+			return _this.tolerances[cat]
+		}
+	}
+	return _this.opt.Tolerance
+}
+
+// metricer is implemented by classifiers with a configurable metric, such as
+// goFace.Classifier.
+type metricer interface {
+	Metric() goFace.Metric
+}
+
+/*
+distance returns the distance between two descriptors in the metric of the
+classifier, squared euclidean like dlib if it has none, so that tolerances
+and margins are in the units of ClassifyThreshold
+*/
+func (_this *Recognizer) distance(D1, D2 goFace.Descriptor) float64 {
+	metric := goFace.SquaredEuclidean
+	if m, ok := _this.classifier.(metricer); ok {
+		metric = m.Metric()
+	}
+	return float64(metric.Distance(D1, D2))
+}
+
+// nearester is implemented by classifiers able to list the nearest samples,
+// such as goFace.Classifier.
+type nearester interface {
+	Nearest(sample goFace.Descriptor, k int) []goFace.Neighbor
+}
+
+/*
+runnerUp returns the distance to the nearest sample of another identity
+than Cat
+*/
+func (_this *Recognizer) runnerUp(Descriptor goFace.Descriptor, Cat int) float64 {
+	if n, ok := _this.classifier.(nearester); ok {
+		for _, neighbor := range n.Nearest(Descriptor, 50) {
+			if neighbor.Index < len(_this.samples) && int(_this.categories[neighbor.Index]) != Cat {
+				return _this.distance(_this.dataset[_this.samples[neighbor.Index]].Descriptor, Descriptor)
+			}
+		}
+		// The identity fills the nearest samples, the other ones are
+		// scanned.
+	}
+	runnerUp := math.Inf(1)
+	for cat, members := range _this.members {
+		if cat == Cat {
+			continue
+		}
+		for _, i := range members {
+			runnerUp = math.Min(runnerUp, _this.distance(_this.dataset[i].Descriptor, Descriptor))
+		}
+	}
+	return runnerUp
+}
+
+/*
+classifyFace returns the classified face, with the Unknown Id if it matches no
+identity, or was embedded by another model than the samples, and
+IncludeUnknown is set
+*/
+func (_this *Recognizer) classifyFace(F goFace.Face, IncludeUnknown bool) (Face, bool) {
+	person, dist, ok := Data{}, float32(-1), false
+	// Descriptors of different models can't be compared.
+	if modelOf(F.Model) == _this.opt.Model {
+		person, dist, ok = _this.classify(F.Descriptor)
+	}
+	if !ok {
+		if !IncludeUnknown {
+			return Face{}, false
+		}
+		person = Data{Id: Unknown, Descriptor: F.Descriptor, Model: modelOf(F.Model)}
+	}
+	return Face{Data: person, Rectangle: F.Rectangle, Distance: dist, Shapes: F.Shapes}, true
+}
+
+/*
+RecognizeSingle returns face if it's the only face on the image or nil otherwise.
+*/
+func (_this *Recognizer) RecognizeSingle(Path string) (goFace.Face, error) {
+	faces, err := _this.RecognizeMultiples(Path)
+	if err != nil {
+		return goFace.Face{}, err
+	}
+	if len(faces) != 1 {
 		return goFace.Face{}, fmt.Errorf("Not a single face on the image")
 	}
-	return *idFace, nil
+	return faces[0], nil
 }
 
 /*
 RecognizeMultiples returns all faces found on the provided image, sorted from
 left to right. Empty list is returned if there are no faces, error is
 returned if there was some error while decoding/processing image.
-Only JPEG format is currently supported.
 */
 func (_this *Recognizer) RecognizeMultiples(Path string) ([]goFace.Face, error) {
-	file := Path
-	var err error
-	if _this.opt.UseGray {
-		file, err = _this.createTempGrayFile(file, "64ab59ac42d69274f06eadb11348969e")
-		if err != nil {
-			return nil, err
-		}
-		defer os.Remove(file)
-	}
-	var idFaces []goFace.Face
-	if _this.opt.UseCNN {
-		idFaces, err = _this.rec.RecognizeFileCNN(file)
-	} else {
-		idFaces, err = _this.rec.RecognizeFile(file)
+	img, err := _this.loadFaceImage(Path)
+	if err != nil {
+		return nil, err
 	}
+	idFaces, err := _this.embedder.RecognizeImage(img)
 	if err != nil {
-		return nil, fmt.Errorf("Can't recognize: %v", err)
+		return nil, fmt.Errorf("Can't recognize: %w", err)
 	}
 	return idFaces, nil
 }
 
+/*
+DetectFaces returns rectangles and confidence scores of all faces found on the
+provided image, sorted from left to right, without computing descriptors.
+Set Landmarks to also get the face shapes. Empty list is returned if there are no faces.
+*/
+func (_this *Recognizer) DetectFaces(Path string, Landmarks bool) ([]goFace.Detection, error) {
+	img, err := _this.loadFaceImage(Path)
+	if err != nil {
+		return nil, err
+	}
+	dets, err := _this.detector.DetectImage(img, Landmarks)
+	if err != nil {
+		return nil, fmt.Errorf("Can't detect: %w", err)
+	}
+	return dets, nil
+}
+
 /*
 Classify returns all faces identified in the image. Empty list is returned if no match.
 */
@@ -185,31 +550,57 @@ func (_this *Recognizer) Classify(Path string) ([]Face, error) {
 	if err != nil {
 		return nil, err
 	}
-	personID := _this.rec.ClassifyThreshold(face.Descriptor, _this.opt.Tolerance)
-	if personID < 0 {
+	aux, ok := _this.classifyFace(face, _this.opt.IncludeUnknown)
+	if !ok {
 		return nil, fmt.Errorf("Can't classify")
 	}
 	facesRec := make([]Face, 0)
-	aux := Face{Data: _this.dataset[personID], Rectangle: face.Rectangle}
 	facesRec = append(facesRec, aux)
 	return facesRec, nil
 }
 
 /*
 ClassifyMultiples returns all faces identified in the image. Empty list is returned if no match.
+Faces matching no identity are dropped, or returned with the Unknown Id if IncludeUnknown is set.
 */
 func (_this *Recognizer) ClassifyMultiples(Path string) ([]Face, error) {
 	faces, err := _this.RecognizeMultiples(Path)
 	if err != nil {
-		return nil, fmt.Errorf("Can't recognize: %v", err)
+		return nil, fmt.Errorf("Can't recognize: %w", err)
 	}
 	facesRec := make([]Face, 0)
 	for _, f := range faces {
-		personID := _this.rec.ClassifyThreshold(f.Descriptor, _this.opt.Tolerance)
-		if personID < 0 {
+		aux, ok := _this.classifyFace(f, _this.opt.IncludeUnknown)
+		if !ok {
+			continue
+		}
+		facesRec = append(facesRec, aux)
+	}
+	return facesRec, nil
+}
+
+/*
+ClassifyImage Same as ClassifyMultiples but classifies an image in memory,
+e.g. a video frame.
+*/
+func (_this *Recognizer) ClassifyImage(Img image.Image) ([]Face, error) {
+	return _this.classifyImage(Img, _this.opt.IncludeUnknown)
+}
+
+func (_this *Recognizer) classifyImage(Img image.Image, IncludeUnknown bool) ([]Face, error) {
+	if _this.opt.UseGray {
+		Img = _this.GrayScale(Img)
+	}
+	faces, err := _this.embedder.RecognizeImage(Img)
+	if err != nil {
+		return nil, fmt.Errorf("Can't recognize: %w", err)
+	}
+	facesRec := make([]Face, 0)
+	for _, f := range faces {
+		aux, ok := _this.classifyFace(f, IncludeUnknown)
+		if !ok {
 			continue
 		}
-		aux := Face{Data: _this.dataset[personID], Rectangle: f.Rectangle}
 		facesRec = append(facesRec, aux)
 	}
 	return facesRec, nil
@@ -278,6 +669,9 @@ func (_this *Recognizer) FilterImageByFacesInImage(dir, file string) ([]FacesInI
 		var foundFaces []Face
 		var faceIds []string
 		for _, f := range classifiedFaces {
+			if f.Id == Unknown {
+				continue
+			}
 			for _, face := range faces {
 				if face.Id == f.Id {
 					foundFaces = append(foundFaces, f)
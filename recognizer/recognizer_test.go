@@ -0,0 +1,170 @@
+package recognizer
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+	"github.com/oarkflow/imaging/recognizer/fake"
+)
+
+func TestClassifyDefaultNearestSample(t *testing.T) {
+	axis := func(i int, v float32) goFace.Descriptor {
+		var d goFace.Descriptor
+		d[i] = v
+		return d
+	}
+	// Alice has the nearest sample, bob the most among the 3 nearest.
+	dataset := []Data{
+		{Id: "alice", Descriptor: axis(0, 0.3)},
+		{Id: "bob", Descriptor: axis(1, 0.35)},
+		{Id: "bob", Descriptor: axis(2, 0.36)},
+	}
+	tests := []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{"default", Option{}, "alice"},
+		{"vote", Option{K: 3}, "bob"},
+		{"weighted vote", Option{K: 3, Strategy: goFace.WeightedVote}, "bob"},
+		{"nearest centroid", Option{Strategy: goFace.NearestCentroid}, "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := fake.New()
+			opt := tt.opt
+			opt.Detector, opt.Embedder = fb, fb
+			rec, err := New(&opt)
+			if err != nil {
+				t.Skipf("default classifier unavailable: %v", err)
+			}
+			defer rec.Close()
+			rec.dataset = dataset
+			rec.SetSamples()
+			person, _, ok := rec.classify(goFace.Descriptor{})
+			if !ok || person.Id != tt.want {
+				t.Errorf("classified as %q (%v), want %q", person.Id, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyMetric(t *testing.T) {
+	axis := func(i int, v float32) goFace.Descriptor {
+		var d goFace.Descriptor
+		d[i] = v
+		return d
+	}
+	near := axis(0, 1)
+	near[1] = 0.05
+	// The query points the same way as alice's samples but is twice as
+	// long: identical in cosine, far in squared euclidean.
+	query := axis(0, 2)
+
+	tests := []struct {
+		name       string
+		classifier Classifier
+		match      bool
+	}{
+		{"cosine", goFace.NewClassifier(goFace.ClassifierOptions{Metric: goFace.Cosine}), true},
+		{"squared euclidean", fake.New(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := fake.New()
+			rec, err := New(&Option{Detector: fb, Embedder: fb, Classifier: tt.classifier, AdaptiveTolerance: true, Margin: 0.5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.dataset = []Data{
+				{Id: "alice", Descriptor: axis(0, 1)},
+				{Id: "alice", Descriptor: near},
+				{Id: "bob", Descriptor: axis(1, 1)},
+			}
+			rec.SetSamples()
+			person, dist, ok := rec.classify(query)
+			if ok != tt.match {
+				t.Fatalf("matched %v (%q at %v), want %v", ok, person.Id, dist, tt.match)
+			}
+			if ok && (person.Id != "alice" || dist > 0.01) {
+				t.Errorf("matched %q at %v, want alice at about 0", person.Id, dist)
+			}
+		})
+	}
+}
+
+func TestClassifyMarginCrowdedIdentity(t *testing.T) {
+	// Alice has more samples than runnerUp asks the classifier for, all
+	// nearer than bob's.
+	var alice []Data
+	for i := 0; i < 60; i++ {
+		var d goFace.Descriptor
+		d[0], d[2] = 0.3, float32(i)*0.0001
+		alice = append(alice, Data{Id: "alice", Descriptor: d})
+	}
+	tests := []struct {
+		name  string
+		bob   float32
+		match bool
+	}{
+		{"close second identity", 0.32, false},
+		{"distant second identity", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := fake.New()
+			rec, err := New(&Option{Detector: fb, Embedder: fb, Classifier: goFace.NewClassifier(goFace.ClassifierOptions{K: 1}), Margin: 0.05})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var bob goFace.Descriptor
+			bob[1] = tt.bob
+			rec.dataset = append(append([]Data(nil), alice...), Data{Id: "bob", Descriptor: bob})
+			rec.SetSamples()
+			if person, _, ok := rec.classify(goFace.Descriptor{}); ok != tt.match {
+				t.Errorf("matched %v (%q), want %v", ok, person.Id, tt.match)
+			}
+		})
+	}
+}
+
+func TestDetectFaces(t *testing.T) {
+	rec, fb := newFakeRecognizer(t, nil)
+	path := filepath.Join(t.TempDir(), "faces.png")
+	if err := imag.Save(noiseImage(200, 100, 1), path); err != nil {
+		t.Fatal(err)
+	}
+	fb.SetRects(image.Rect(120, 10, 180, 70), image.Rect(10, 10, 70, 70))
+
+	tests := []struct {
+		name      string
+		landmarks bool
+		shapes    int
+	}{
+		{"rectangles", false, 0},
+		{"landmarks", true, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dets, err := rec.DetectFaces(path, tt.landmarks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dets) != 2 {
+				t.Fatalf("detected %d faces, want 2", len(dets))
+			}
+			for _, d := range dets {
+				if len(d.Shapes) != tt.shapes || d.Confidence <= 0 {
+					t.Errorf("got %v with %d landmarks at %v, want %d", d.Rectangle, len(d.Shapes), d.Confidence, tt.shapes)
+				}
+			}
+		})
+	}
+
+	if _, err := rec.DetectFaces(filepath.Join(t.TempDir(), "missing.png"), false); err == nil {
+		t.Error("detected faces on a missing image")
+	}
+}
@@ -0,0 +1,306 @@
+package recognizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// StreamOptions configures ProcessStream and ProcessFrames.
+type StreamOptions struct {
+	// Stride processes one frame out of Stride, 1 if zero.
+	Stride int
+	// Buffer is the capacity of the results channel, 16 if zero. Results
+	// are dropped rather than queued when it is full, unless ProcessFrames
+	// runs without FrameRate.
+	Buffer int
+	// Tracker, if set, tracks the faces across the processed frames, the
+	// results then include the tracks.
+	Tracker *Tracker
+	// FrameRate paces ProcessFrames like a camera, dropping the frames
+	// arriving while the previous one is processed. Zero processes every
+	// frame of the directory (honoring Stride) as fast as possible.
+	FrameRate float64
+}
+
+// FrameResult is the result of a processed frame.
+type FrameResult struct {
+	// Frame is the number of the frame in the stream, from 0.
+	Frame int
+	// Time is when the frame was received, or for frame directories the
+	// time of the frame derived from FrameRate or the file time.
+	Time  time.Time
+	Faces []Face
+	// Tracks holds the track of every face if StreamOptions.Tracker is set.
+	Tracks []Track
+	// Dropped is the number of frames and results dropped since the
+	// previous result because processing or the consumer was too slow.
+	Dropped int
+	// Err is set if the frame couldn't be processed. If reading the stream
+	// failed, the last result has Frame -1 and the error.
+	Err error
+}
+
+// streamFrame is a frame read from a stream, not yet decoded.
+type streamFrame struct {
+	n    int
+	time time.Time
+	data []byte
+}
+
+/*
+ProcessStream processes the frames of an MJPEG stream, i.e. a
+multipart/x-mixed-replace body, until its end or the cancellation of Ctx.
+Boundary is the boundary parameter of its Content-Type, sniffed from the
+stream if empty. Frames arriving while the previous one is processed are
+dropped. The returned channel is closed once done, close R to interrupt a
+blocked read.
+*/
+func (_this *Recognizer) ProcessStream(Ctx context.Context, R io.Reader, Boundary string, Opt StreamOptions) <-chan FrameResult {
+	br := bufio.NewReader(R)
+	// Some cameras include the leading dashes in the Content-Type.
+	Boundary = strings.TrimPrefix(Boundary, "--")
+	var parts *multipart.Reader
+	n := 0
+	next := func() (*streamFrame, error) {
+		if parts == nil {
+			if Boundary == "" {
+				var err error
+				if Boundary, err = sniffBoundary(br); err != nil {
+					return nil, err
+				}
+			}
+			parts = multipart.NewReader(br, Boundary)
+		}
+		part, err := parts.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		defer part.Close()
+		var r io.Reader = part
+		if limit := _this.opt.Limits.MaxFileSize; limit > 0 {
+			// Read one byte more than allowed to detect oversized frames.
+			r = io.LimitReader(part, limit+1)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		f := &streamFrame{n: n, time: time.Now(), data: data}
+		n++
+		return f, nil
+	}
+	return _this.processFrames(Ctx, next, Opt, true)
+}
+
+/*
+ProcessFrames processes the image files of a directory in the order of the
+number in their names, e.g. frame-0001.jpg, frame-0002.jpg, until the last
+one or the cancellation of Ctx. The returned channel is closed once done.
+*/
+func (_this *Recognizer) ProcessFrames(Ctx context.Context, Dir string, Opt StreamOptions) <-chan FrameResult {
+	files, err := frameFiles(Dir)
+	n := 0
+	start := time.Now()
+	next := func() (*streamFrame, error) {
+		if err != nil {
+			return nil, err
+		}
+		if n >= len(files) {
+			return nil, io.EOF
+		}
+		path := filepath.Join(Dir, files[n])
+		f := &streamFrame{n: n}
+		if Opt.FrameRate > 0 {
+			f.time = start.Add(time.Duration(float64(n) / Opt.FrameRate * float64(time.Second)))
+			timer := time.NewTimer(time.Until(f.time))
+			select {
+			case <-timer.C:
+			case <-Ctx.Done():
+				// Stop at the cancellation rather than the next frame time.
+				timer.Stop()
+				return nil, io.EOF
+			}
+		} else if info, err := os.Stat(path); err == nil {
+			f.time = info.ModTime()
+		}
+		n++
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return nil, readErr
+		}
+		f.data = data
+		return f, nil
+	}
+	return _this.processFrames(Ctx, next, Opt, Opt.FrameRate > 0)
+}
+
+/*
+processFrames reads frames with Next and processes them in the background.
+If Realtime is set, frames read while the previous one is processed and
+results the consumer isn't ready for are dropped instead of waiting.
+*/
+func (_this *Recognizer) processFrames(Ctx context.Context, Next func() (*streamFrame, error), Opt StreamOptions, Realtime bool) <-chan FrameResult {
+	if Opt.Stride <= 0 {
+		Opt.Stride = 1
+	}
+	if Opt.Buffer <= 0 {
+		Opt.Buffer = 16
+	}
+	results := make(chan FrameResult, Opt.Buffer)
+	// Holds at most one frame: the next one to process.
+	pending := make(chan *streamFrame, 1)
+	readErr := make(chan error, 1)
+	var dropped atomic.Int64
+
+	go func() {
+		defer close(pending)
+		for Ctx.Err() == nil {
+			f, err := Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr <- err
+				}
+				return
+			}
+			if f.n%Opt.Stride != 0 {
+				continue
+			}
+			if !Realtime {
+				select {
+				case pending <- f:
+				case <-Ctx.Done():
+				}
+				continue
+			}
+			select {
+			case pending <- f:
+			default:
+				// Replace the frame still waiting by the fresher one.
+				select {
+				case <-pending:
+					dropped.Add(1)
+				default:
+				}
+				pending <- f
+			}
+		}
+	}()
+
+	go func() {
+		defer close(results)
+		for f := range pending {
+			res := _this.processFrame(f, Opt.Tracker)
+			res.Dropped = int(dropped.Swap(0))
+			if !Realtime {
+				select {
+				case results <- res:
+				case <-Ctx.Done():
+				}
+				continue
+			}
+			select {
+			case results <- res:
+			default:
+				dropped.Add(int64(res.Dropped) + 1)
+			}
+		}
+		select {
+		case err := <-readErr:
+			select {
+			case results <- FrameResult{Frame: -1, Time: time.Now(), Err: err, Dropped: int(dropped.Load())}:
+			case <-Ctx.Done():
+			}
+		default:
+		}
+	}()
+	return results
+}
+
+/*
+processFrame decodes and classifies the frame
+*/
+func (_this *Recognizer) processFrame(F *streamFrame, Tracker *Tracker) FrameResult {
+	res := FrameResult{Frame: F.n, Time: F.time}
+	if err := _this.opt.Limits.CheckFileSize(int64(len(F.data))); err != nil {
+		res.Err = err
+		return res
+	}
+	img, err := _this.opt.Limits.Decode(bytes.NewReader(F.data))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if Tracker != nil {
+		res.Faces, res.Err = _this.classifyImage(img, true)
+		if res.Err == nil {
+			res.Tracks = Tracker.Update(res.Faces)
+		}
+		return res
+	}
+	res.Faces, res.Err = _this.ClassifyImage(img)
+	return res
+}
+
+/*
+sniffBoundary reads the first boundary line of a multipart stream and
+returns the boundary, leaving the line to be read
+*/
+func sniffBoundary(R *bufio.Reader) (string, error) {
+	for size := 64; ; size *= 2 {
+		head, err := R.Peek(size)
+		if i := bytes.IndexByte(head, '\n'); i >= 0 {
+			line := strings.TrimSpace(string(head[:i]))
+			if !strings.HasPrefix(line, "--") || len(line) == 2 {
+				return "", fmt.Errorf("Can't find the multipart boundary")
+			}
+			return line[2:], nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("Can't find the multipart boundary: %w", err)
+		}
+	}
+}
+
+var frameNumber = regexp.MustCompile(`\d+`)
+
+/*
+frameFiles returns the files of the directory sorted by the last number in
+their names, then by name
+*/
+func frameFiles(Dir string) ([]string, error) {
+	entries, err := os.ReadDir(Dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	numbers := make(map[string]int)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		files = append(files, e.Name())
+		if all := frameNumber.FindAllString(e.Name(), -1); len(all) > 0 {
+			numbers[e.Name()], _ = strconv.Atoi(all[len(all)-1])
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if numbers[files[i]] != numbers[files[j]] {
+			return numbers[files[i]] < numbers[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
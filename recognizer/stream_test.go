@@ -0,0 +1,93 @@
+package recognizer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+	"github.com/oarkflow/imaging/imag"
+)
+
+// mjpeg returns a multipart/x-mixed-replace body of n JPEG frames.
+func mjpeg(t *testing.T, n int, boundary string) []byte {
+	t.Helper()
+	var frame bytes.Buffer
+	if err := jpeg.Encode(&frame, noiseImage(64, 48, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&body, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", boundary, frame.Len())
+		body.Write(frame.Bytes())
+		body.WriteString("\r\n")
+	}
+	fmt.Fprintf(&body, "--%s--\r\n", boundary)
+	return body.Bytes()
+}
+
+func TestProcessStreamLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   goFace.Limits
+		boundary string
+		decoded  bool
+	}{
+		{"zero limits", goFace.Limits{}, "frame", true},
+		{"default limits", goFace.DefaultLimits, "", true},
+		{"frames too large", goFace.Limits{MaxFileSize: 100}, "frame", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.limits
+			rec, fb := newFakeRecognizer(t, &Option{Limits: &limits})
+			fb.SetRects(image.Rect(0, 0, 32, 32))
+			results := rec.ProcessStream(context.Background(), bytes.NewReader(mjpeg(t, 3, "frame")), tt.boundary, StreamOptions{Buffer: 8})
+			frames := 0
+			for res := range results {
+				if res.Frame < 0 {
+					t.Fatalf("stream failed: %v", res.Err)
+				}
+				frames++
+				if decoded := res.Err == nil; decoded != tt.decoded {
+					t.Errorf("frame %d: err %v", res.Frame, res.Err)
+				}
+			}
+			if frames == 0 {
+				t.Fatal("no frame processed")
+			}
+		})
+	}
+}
+
+func TestProcessFramesCancel(t *testing.T) {
+	dir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("frame-%04d.png", i))
+		if err := imag.Save(noiseImage(32, 32, int64(i)), path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec, _ := newFakeRecognizer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// A frame every 10 seconds: the second one is still awaited when the
+	// first result comes.
+	results := rec.ProcessFrames(ctx, dir, StreamOptions{FrameRate: 0.1})
+	if res := <-results; res.Frame != 0 || res.Err != nil {
+		t.Fatalf("got frame %d (%v), want frame 0", res.Frame, res.Err)
+	}
+	start := time.Now()
+	cancel()
+	for res := range results {
+		t.Errorf("got frame %d (%v) after the cancellation", res.Frame, res.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("closed %v after the cancellation", elapsed)
+	}
+}
@@ -0,0 +1,245 @@
+package recognizer
+
+import (
+	"image"
+	"sort"
+	"sync"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+// TrackerOptions configures a Tracker. Zero values are replaced by
+// defaults suited to a camera at 10 to 30 frames per second.
+type TrackerOptions struct {
+	// MinIoU is the overlap with the last rectangle of a track above which
+	// a face continues it.
+	MinIoU float64
+	// MaxDistance is the squared euclidean distance to the last descriptor
+	// of a track below which a face continues it, e.g. after moving fast
+	// or reappearing.
+	MaxDistance float32
+	// MaxAge is the number of frames a track survives without faces, to
+	// bridge occlusions.
+	MaxAge int
+	// MinVotes is the number of frames classified as the same identity
+	// needed to decide the identity of a track.
+	MinVotes int
+	// Liveness configures the liveness check of the tracks.
+	Liveness LivenessOptions
+}
+
+// A Track follows a face across frames.
+type Track struct {
+	// ID is stable for the life of the track.
+	ID         int
+	Rectangle  image.Rectangle
+	Descriptor goFace.Descriptor
+	// Id is the identity with most votes over the classified frames,
+	// Unknown until it reaches MinVotes.
+	Id string
+	// Votes counts the frames classified as every identity.
+	Votes map[string]int
+	// FirstFrame and LastFrame are the frame numbers where the track was
+	// first and last seen, Frames the number of frames it was seen in.
+	FirstFrame int
+	LastFrame  int
+	Frames     int
+	// Liveness is updated from the landmarks of every frame.
+	Liveness Liveness
+	live     *liveness
+}
+
+// A Tracker associates faces of consecutive frames into tracks by overlap
+// and descriptor similarity. Thread-safe.
+type Tracker struct {
+	opt     TrackerOptions
+	mu      sync.Mutex
+	frame   int
+	nextID  int
+	tracks  []*Track
+	expired []Track
+}
+
+// NewTracker returns a tracker without tracks.
+func NewTracker(opt TrackerOptions) *Tracker {
+	if opt.MinIoU <= 0 {
+		opt.MinIoU = 0.3
+	}
+	if opt.MaxDistance <= 0 {
+		opt.MaxDistance = 0.36
+	}
+	if opt.MaxAge <= 0 {
+		opt.MaxAge = 15
+	}
+	if opt.MinVotes <= 0 {
+		opt.MinVotes = 3
+	}
+	return &Tracker{opt: opt, nextID: 1}
+}
+
+/*
+TrackImage classifies the faces of the next frame, including the unknown ones,
+and updates the tracker with them
+*/
+func (_this *Recognizer) TrackImage(Tracker *Tracker, Img image.Image) ([]Track, error) {
+	faces, err := _this.classifyImage(Img, true)
+	if err != nil {
+		return nil, err
+	}
+	return Tracker.Update(faces), nil
+}
+
+// Update associates the faces of the next frame with the tracks and returns
+// the tracks seen in this frame, in the order of faces. Faces with an empty
+// Id, e.g. not classified, don't vote.
+func (t *Tracker) Update(faces []Face) []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frame++
+
+	// Greedy association, best scoring pairs first.
+	type match struct {
+		track, face int
+		score       float64
+	}
+	var matches []match
+	for i, track := range t.tracks {
+		for j, f := range faces {
+			if score, ok := t.score(track, f); ok {
+				matches = append(matches, match{i, j, score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+	assigned := make([]*Track, len(faces))
+	taken := make(map[int]bool)
+	for _, m := range matches {
+		if taken[m.track] || assigned[m.face] != nil {
+			continue
+		}
+		taken[m.track] = true
+		assigned[m.face] = t.tracks[m.track]
+	}
+
+	seen := make([]Track, len(faces))
+	for j, f := range faces {
+		track := assigned[j]
+		if track == nil {
+			track = &Track{
+				ID:         t.nextID,
+				FirstFrame: t.frame,
+				Votes:      make(map[string]int),
+				live:       newLiveness(t.opt.Liveness),
+			}
+			t.nextID++
+			t.tracks = append(t.tracks, track)
+		}
+		t.observe(track, f)
+		seen[j] = track.copy()
+	}
+
+	tracks := t.tracks[:0]
+	for _, track := range t.tracks {
+		if t.frame-track.LastFrame > t.opt.MaxAge {
+			t.expired = append(t.expired, track.copy())
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+	t.tracks = tracks
+	return seen
+}
+
+// Tracks returns the live tracks, including those hidden for less than
+// MaxAge frames.
+func (t *Tracker) Tracks() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracks := make([]Track, len(t.tracks))
+	for i, track := range t.tracks {
+		tracks[i] = track.copy()
+	}
+	return tracks
+}
+
+// Expired returns the tracks which ended since the last call, with their
+// final identity.
+func (t *Tracker) Expired() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	expired := t.expired
+	t.expired = nil
+	return expired
+}
+
+// Flush ends all tracks, e.g. at the end of a video, and returns them along
+// with those not yet returned by Expired.
+func (t *Tracker) Flush() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, track := range t.tracks {
+		t.expired = append(t.expired, track.copy())
+	}
+	t.tracks = nil
+	expired := t.expired
+	t.expired = nil
+	return expired
+}
+
+// score tells whether the face can continue the track and how well.
+func (t *Tracker) score(track *Track, f Face) (float64, bool) {
+	overlap := iou(track.Rectangle, f.Rectangle)
+	dist := float32(goFace.SquaredEuclideanDistance(track.Descriptor, f.Descriptor))
+	similar := dist <= t.opt.MaxDistance
+	if overlap < t.opt.MinIoU && !similar {
+		return 0, false
+	}
+	score := overlap
+	if similar {
+		score += 1 - float64(dist/t.opt.MaxDistance)
+	}
+	return score, true
+}
+
+func (t *Tracker) observe(track *Track, f Face) {
+	track.Rectangle = f.Rectangle
+	track.Descriptor = f.Descriptor
+	track.LastFrame = t.frame
+	track.Frames++
+	track.live.update(f.Shapes)
+	track.Liveness = track.live.result
+	if f.Id != "" {
+		track.Votes[f.Id]++
+	}
+	// Unknown frames vote too, a track mostly unknown stays unknown.
+	best, bestID := 0, Unknown
+	for id, votes := range track.Votes {
+		if votes > best || (votes == best && id < bestID) {
+			best, bestID = votes, id
+		}
+	}
+	track.Id = Unknown
+	if best >= t.opt.MinVotes {
+		track.Id = bestID
+	}
+}
+
+func (track *Track) copy() Track {
+	c := *track
+	c.live = nil
+	c.Votes = make(map[string]int, len(track.Votes))
+	for id, votes := range track.Votes {
+		c.Votes[id] = votes
+	}
+	return c
+}
+
+// iou returns the intersection over union of two rectangles.
+func iou(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	i := float64(inter.Dx() * inter.Dy())
+	return i / (float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - i)
+}
@@ -0,0 +1,96 @@
+package recognizer
+
+import (
+	"image"
+	"testing"
+
+	goFace "github.com/oarkflow/imaging/go-face"
+)
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		a, b image.Rectangle
+		want float64
+	}{
+		{image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 50.0 / 150},
+		{image.Rect(0, 0, 10, 10), image.Rect(2, 2, 8, 8), 36.0 / 100},
+		{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10), 0},
+		{image.Rect(0, 0, 10, 10), image.Rectangle{}, 0},
+	}
+	for _, tt := range tests {
+		if got := iou(tt.a, tt.b); got != tt.want {
+			t.Errorf("iou(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerMatching(t *testing.T) {
+	axis := func(i int) goFace.Descriptor {
+		var d goFace.Descriptor
+		d[i] = 1
+		return d
+	}
+	face := func(x int, d goFace.Descriptor) Face {
+		return Face{Data: Data{Descriptor: d}, Rectangle: image.Rect(x, 0, x+100, 100)}
+	}
+	first := []Face{face(0, axis(0)), face(300, axis(1))}
+
+	tests := []struct {
+		name string
+		next []Face
+		// want holds the track ID of every face, new tracks from 3.
+		want []int
+	}{
+		{"moved a bit", []Face{face(10, axis(0)), face(290, axis(1))}, []int{1, 2}},
+		{"order swapped", []Face{face(290, axis(1)), face(10, axis(0))}, []int{2, 1}},
+		// The overlap wins over descriptors, e.g. when the light changes.
+		{"overlap only", []Face{face(20, axis(5)), face(320, axis(6))}, []int{1, 2}},
+		// Descriptors match faces that jumped, e.g. after a cut.
+		{"descriptor only", []Face{face(600, axis(0)), face(900, axis(1))}, []int{1, 2}},
+		// Faces crossing: a weak overlap loses against the descriptors.
+		{"crossing", []Face{face(50, axis(1)), face(250, axis(0))}, []int{2, 1}},
+		{"new face", []Face{face(0, axis(0)), face(600, axis(7))}, []int{1, 3}},
+		{"two faces on one track", []Face{face(0, axis(0)), face(20, axis(8))}, []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker(TrackerOptions{})
+			tracker.Update(first)
+			tracks := tracker.Update(tt.next)
+			for i, track := range tracks {
+				if track.ID != tt.want[i] {
+					t.Errorf("face %d on track %d, want %d", i, track.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrackerVotes(t *testing.T) {
+	tracker := NewTracker(TrackerOptions{MinVotes: 2, MaxAge: 1})
+	f := Face{Data: Data{Id: "alice"}, Rectangle: image.Rect(0, 0, 100, 100)}
+	unknown := f
+	unknown.Id = Unknown
+
+	tests := []struct {
+		face Face
+		want string
+	}{
+		{f, Unknown},
+		{unknown, Unknown},
+		{f, "alice"},
+		{unknown, "alice"},
+		{unknown, Unknown},
+	}
+	for i, tt := range tests {
+		if got := tracker.Update([]Face{tt.face})[0]; got.Id != tt.want || got.ID != 1 {
+			t.Errorf("frame %d: track %d is %q, want 1 %q", i+1, got.ID, got.Id, tt.want)
+		}
+	}
+	tracker.Update(nil)
+	tracker.Update(nil)
+	if expired := tracker.Expired(); len(expired) != 1 || expired[0].Frames != len(tests) {
+		t.Errorf("expired %+v, want the track seen in %d frames", expired, len(tests))
+	}
+}